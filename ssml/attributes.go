@@ -0,0 +1,302 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SSML 1.1 属性值的语法
+var (
+	timePattern          = regexp.MustCompile(`^(\d+(\.\d*)?|\.\d+)(s|ms)$`)
+	hertzPattern         = regexp.MustCompile(`^(\d+(\.\d*)?|\.\d+)Hz$`)
+	relativePitchPattern = regexp.MustCompile(`^[+-](\d+(\.\d*)?|\.\d+)(Hz|st|%)$`)
+	percentPattern       = regexp.MustCompile(`^(\d+(\.\d*)?|\.\d+)%$`)
+	relativeRatePattern  = regexp.MustCompile(`^[+-](\d+(\.\d*)?|\.\d+)%$`)
+	decibelPattern       = regexp.MustCompile(`^[+-](\d+(\.\d*)?|\.\d+)dB$`)
+	numberPattern        = regexp.MustCompile(`^(\d+(\.\d*)?|\.\d+)$`)
+	integerPattern       = regexp.MustCompile(`^\d+$`)
+	positivePattern      = regexp.MustCompile(`^0*[1-9]\d*$`)
+	vendorAlphabet       = regexp.MustCompile(`^x-\S+$`)
+)
+
+// 属性的关键字取值
+var (
+	strengthValues      = []string{"none", "x-weak", "weak", "medium", "strong", "x-strong"}
+	levelValues         = []string{"strong", "moderate", "none", "reduced"}
+	pitchValues         = []string{"x-low", "low", "medium", "high", "x-high", "default"}
+	rateValues          = []string{"x-slow", "slow", "medium", "fast", "x-fast", "default"}
+	volumeValues        = []string{"silent", "x-soft", "soft", "medium", "loud", "x-loud", "default"}
+	genderValues        = []string{"male", "female", "neutral"}
+	onLangFailureValues = []string{"changevoice", "ignoretext", "ignorelang", "processorchoice"}
+	versionValues       = []string{"1.0", "1.1"}
+)
+
+// 属性语法中的模式列表和合法取值的描述，预先创建以免每个节点都重新分配
+var (
+	timePatterns     = []*regexp.Regexp{timePattern}
+	pitchPatterns    = []*regexp.Regexp{hertzPattern, relativePitchPattern}
+	percentPatterns  = []*regexp.Regexp{percentPattern}
+	ratePatterns     = []*regexp.Regexp{percentPattern, relativeRatePattern}
+	decibelPatterns  = []*regexp.Regexp{decibelPattern}
+	numberPatterns   = []*regexp.Regexp{numberPattern}
+	integerPatterns  = []*regexp.Regexp{integerPattern}
+	positivePatterns = []*regexp.Regexp{positivePattern}
+	alphabetPatterns = []*regexp.Regexp{vendorAlphabet}
+	ipaKeywords      = []string{"ipa"}
+
+	pitchExpected  = `"120Hz", a relative change such as "+10%", "-2st" or "+20Hz", or one of ` + quoteList(pitchValues)
+	rateExpected   = `a percentage such as "80%", a relative change such as "+10%" or "-20%", or one of ` + quoteList(rateValues)
+	volumeExpected = `a relative change such as "+6dB" or "-6dB", or one of ` + quoteList(volumeValues)
+)
+
+// attributeRule 一个属性的语法，value 不为空时检查
+type attributeRule struct {
+	name     string
+	value    string
+	keywords []string
+	patterns []*regexp.Regexp
+	expected string // 诊断信息中对合法取值的描述，为空时由 keywords 生成
+}
+
+// matches 判断取值是否符合语法
+func (r attributeRule) matches() bool {
+	for _, keyword := range r.keywords {
+		if r.value == keyword {
+			return true
+		}
+	}
+	for _, pattern := range r.patterns {
+		if pattern.MatchString(r.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// description 返回合法取值的描述，只在报告问题时生成
+func (r attributeRule) description() string {
+	if r.expected != "" {
+		return r.expected
+	}
+	return "one of " + quoteList(r.keywords)
+}
+
+// timeRule 时间属性，如 "1s"、"500ms"
+func timeRule(name, value string) attributeRule {
+	return attributeRule{name: name, value: value, patterns: timePatterns, expected: `a time such as "500ms" or "1.5s"`}
+}
+
+// pitchRule pitch 和 range 属性
+func pitchRule(name, value string) attributeRule {
+	return attributeRule{name: name, value: value, keywords: pitchValues, patterns: pitchPatterns, expected: pitchExpected}
+}
+
+// keywordRule 只能取关键字的属性
+func keywordRule(name, value string, keywords []string) attributeRule {
+	return attributeRule{name: name, value: value, keywords: keywords}
+}
+
+// appendAttributeRules 把节点中需要验证的属性追加到 rules 中；调用方传入栈上的数组，验证时不分配内存
+func appendAttributeRules(rules []attributeRule, node interface{}) []attributeRule {
+	switch n := node.(type) {
+	case *Speak:
+		return append(rules,
+			keywordRule("version", n.Version, versionValues),
+			keywordRule("onlangfailure", n.OnLangFailure, onLangFailureValues),
+		)
+	case *Break:
+		return append(rules,
+			timeRule("time", n.Time),
+			keywordRule("strength", n.Strength, strengthValues),
+		)
+	case *Emphasis:
+		return append(rules, keywordRule("level", n.Level, levelValues))
+	case *Prosody:
+		return append(rules,
+			attributeRule{name: "rate", value: n.Rate, keywords: rateValues, patterns: ratePatterns, expected: rateExpected},
+			pitchRule("pitch", n.Pitch),
+			pitchRule("range", n.Range),
+			attributeRule{name: "volume", value: n.Volume, keywords: volumeValues, patterns: decibelPatterns, expected: volumeExpected},
+			timeRule("duration", attrValue(n.Attrs, "duration")),
+		)
+	case *Phoneme:
+		return append(rules, attributeRule{
+			name:     "alphabet",
+			value:    n.Alphabet,
+			keywords: ipaKeywords,
+			patterns: alphabetPatterns,
+			expected: `"ipa" or a vendor alphabet prefixed with "x-" such as "x-sampa"`,
+		})
+	case *Voice:
+		return append(rules,
+			keywordRule("gender", n.Gender, genderValues),
+			attributeRule{name: "age", value: n.Age, patterns: integerPatterns, expected: "a non-negative integer"},
+			attributeRule{name: "variant", value: n.Variant, patterns: positivePatterns, expected: "a positive integer"},
+			keywordRule("onlangfailure", n.OnLangFailure, onLangFailureValues),
+		)
+	case *Lang:
+		return append(rules, keywordRule("onlangfailure", n.OnLangFailure, onLangFailureValues))
+	case *Audio:
+		return append(rules,
+			timeRule("clipBegin", attrValue(n.Attrs, "clipBegin")),
+			timeRule("clipEnd", attrValue(n.Attrs, "clipEnd")),
+			attributeRule{name: "repeatCount", value: attrValue(n.Attrs, "repeatCount"), patterns: numberPatterns, expected: "a non-negative number"},
+			timeRule("repeatDur", attrValue(n.Attrs, "repeatDur")),
+			attributeRule{name: "soundLevel", value: attrValue(n.Attrs, "soundLevel"), patterns: decibelPatterns, expected: `a relative change such as "+6dB" or "-6dB"`},
+			attributeRule{name: "speed", value: attrValue(n.Attrs, "speed"), patterns: percentPatterns, expected: `a non-negative percentage such as "150%"`},
+		)
+	}
+	return rules
+}
+
+// appendRequiredAttributes 把节点中必须有值的属性按名称顺序追加到 required 中
+func appendRequiredAttributes(required []attributeRule, node interface{}) []attributeRule {
+	switch n := node.(type) {
+	case *Audio:
+		return append(required, attributeRule{name: "src", value: n.Src})
+	case *Lexicon:
+		return append(required, attributeRule{name: "uri", value: n.URI}, attributeRule{name: "xml:id", value: n.ID})
+	case *Lookup:
+		return append(required, attributeRule{name: "ref", value: n.Ref})
+	case *Mark:
+		return append(required, attributeRule{name: "name", value: n.Name})
+	case *Phoneme:
+		return append(required, attributeRule{name: "ph", value: n.Ph})
+	case *Sub:
+		return append(required, attributeRule{name: "alias", value: n.Alias})
+	case *SayAs:
+		return append(required, attributeRule{name: "interpret-as", value: n.InterpretAs})
+	case *Lang:
+		return append(required, attributeRule{name: "xml:lang", value: n.Lang})
+	}
+	return required
+}
+
+// validateAttributes 按 SSML 1.1 的语法验证 nodes 及其所有子元素的属性值
+func (p *Parser) validateAttributes(nodes []interface{}, severity Severity, result *ParseResult) error {
+	return p.newValidation(severity, result, (*validation).attributes).walk(nodes)
+}
+
+// attributes 验证节点的属性值
+func (v *validation) attributes(node interface{}) {
+	for _, err := range v.p.checkAttributes(node, v.severity, v.result) {
+		v.fail(err)
+	}
+}
+
+// checkAttributes 验证单个节点的属性值，严格验证时返回发现的错误
+func (p *Parser) checkAttributes(node interface{}, severity Severity, result *ParseResult) []error {
+	var errs []error
+	span := spanOf(node)
+
+	var buf [8]attributeRule
+	for _, required := range appendRequiredAttributes(buf[:0], node) {
+		if strings.TrimSpace(required.value) != "" {
+			continue
+		}
+		name, element := required.name, elementName(node)
+		p.report(result, Diagnostic{
+			Code:     CodeInvalidAttribute,
+			Severity: severity,
+			Message:  fmt.Sprintf("<%s> requires attribute '%s'", element, name),
+			Span:     span,
+			Node:     node,
+			Fix:      fmt.Sprintf(`add %s="..." to <%s>`, name, element),
+		})
+		if severity == SeverityError {
+			errs = append(errs, fmt.Errorf("%s: <%s> requires attribute '%s'", span.Start, element, name))
+		}
+	}
+
+	for _, rule := range appendAttributeRules(buf[:0], node) {
+		if rule.value == "" || rule.matches() {
+			continue
+		}
+		element := elementName(node)
+		p.report(result, Diagnostic{
+			Code:     CodeInvalidAttribute,
+			Severity: severity,
+			Message:  fmt.Sprintf("Invalid value %q for attribute '%s' on <%s>", rule.value, rule.name, element),
+			Span:     span,
+			Node:     node,
+			Fix:      fmt.Sprintf("%s should be %s", rule.name, rule.description()),
+		})
+		if severity == SeverityError {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q for attribute '%s' on <%s>", span.Start, rule.value, rule.name, element))
+		}
+	}
+
+	return errs
+}
+
+// elementName 返回节点的元素名
+func elementName(node interface{}) string {
+	switch n := node.(type) {
+	case *Speak:
+		return "speak"
+	case *Lexicon:
+		return "lexicon"
+	case *Lookup:
+		return "lookup"
+	case *Mark:
+		return "mark"
+	case *Phoneme:
+		return "phoneme"
+	case *Sub:
+		return "sub"
+	case *SayAs:
+		return "say-as"
+	case *Lang:
+		return "lang"
+	case *Audio:
+		return "audio"
+	case *Desc:
+		return "desc"
+	case *Break:
+		return "break"
+	case *Emphasis:
+		return "emphasis"
+	case *Prosody:
+		return "prosody"
+	case *Voice:
+		return "voice"
+	case *UnknownElement:
+		return qualifiedName(n.XMLName)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+// attrValue 返回未解析为字段的属性的值
+func attrValue(attrs []xml.Attr, local string) string {
+	for _, attr := range attrs {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// quoteList 将关键字列表格式化为 "a", "b" or "c"
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = `"` + value + `"`
+	}
+	if len(quoted) < 2 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
+
+// sortedKeys 返回按字母排序的键，使诊断信息的顺序稳定
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,10 +1,13 @@
 package ssml
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // AudioSegment 表示一个音频片段
@@ -24,14 +27,32 @@ type AudioProperties struct {
 	Gender   string // 性别：male, female, neutral
 	Language string // 语言
 	Emphasis string // 强调级别：none, reduced, moderate, strong
+
+	InterpretAs string // say-as 读法：date, cardinal, telephone 等
+	Format      string // say-as 格式：如日期的 ymd
+	Detail      string // say-as 细节
+	Alphabet    string // 音标字母表：ipa, x-sampa 等
+	Phoneme     string // 音标（来自 phoneme 元素或词典）
+
+	OnLangFailure string // 语言不受支持时的处理方式：changevoice, ignoretext, ignorelang, processorchoice
+
+	Style            string // mstts:express-as 说话风格：cheerful, sad 等
+	StyleDegree      string // mstts:express-as 风格强度
+	Role             string // mstts:express-as 角色扮演
+	Effect           string // amazon:effect 效果：whispered, drc 等
+	Phonation        string // amazon:effect 发声方式
+	VocalTractLength string // amazon:effect 声道长度
+	Domain           string // amazon:domain 领域：news, conversational 等
 }
 
 // AudioInstruction 表示音频处理指令
 type AudioInstruction struct {
-	Type       string           // 指令类型：break, audio, silence, emphasis
+	Type       string           // 指令类型：break, audio, silence, emphasis, mark
 	Position   int              // 在文本中的位置（字符索引）
+	StartTime  time.Duration    // 在最终音频中的预计时间
 	Duration   time.Duration    // 持续时间（用于 break）
 	AudioFile  string           // 音频文件路径（用于 audio）
+	MarkName   string           // 书签名称（用于 mark）
 	Properties *AudioProperties // 音频属性变更
 }
 
@@ -46,7 +67,9 @@ type AudioProcessingResult struct {
 // AudioProcessor 音频处理器
 type AudioProcessor struct {
 	defaultProperties *AudioProperties
-	charToTimeRatio   time.Duration // 每字符的预计发音时间
+	charToTimeRatio   time.Duration    // 每字符的预计发音时间
+	lexicons          *LexiconRegistry // 发音词典注册表，为 nil 时忽略 lookup
+	elements          *ElementRegistry // 自定义元素注册表，为 nil 时使用 DefaultElementRegistry
 }
 
 // NewAudioProcessor 创建新的音频处理器
@@ -65,27 +88,67 @@ func NewAudioProcessor() *AudioProcessor {
 	}
 }
 
+// SetElementRegistry 设置自定义元素注册表
+func (ap *AudioProcessor) SetElementRegistry(registry *ElementRegistry) {
+	ap.elements = registry
+}
+
+// registry 返回音频处理使用的自定义元素注册表
+func (ap *AudioProcessor) registry() *ElementRegistry {
+	if ap.elements != nil {
+		return ap.elements
+	}
+	return DefaultElementRegistry
+}
+
+// SetLexiconRegistry 设置用于解析 lexicon 的词典注册表
+func (ap *AudioProcessor) SetLexiconRegistry(registry *LexiconRegistry) {
+	ap.lexicons = registry
+}
+
 // ProcessSSML 处理 SSML 并生成音频处理结果
 func (ap *AudioProcessor) ProcessSSML(speak *Speak) (*AudioProcessingResult, error) {
+	return ap.ProcessSSMLContext(context.Background(), speak)
+}
+
+// ProcessSSMLContext 处理 SSML 并生成音频处理结果，处理每个节点前检查 ctx，
+// 取消时返回的错误包装了 ctx.Err() 和停止的位置
+func (ap *AudioProcessor) ProcessSSMLContext(runCtx context.Context, speak *Speak) (*AudioProcessingResult, error) {
 	result := &AudioProcessingResult{
 		Segments:     make([]AudioSegment, 0),
 		Instructions: make([]AudioInstruction, 0),
 	}
 
+	lexicons, err := ap.resolveLexicons(runCtx, speak)
+	if err != nil {
+		return nil, err
+	}
+
+	baseProps := ap.baseProperties(speak)
+
 	// 创建处理上下文
 	ctx := &processingContext{
 		processor:        ap,
 		result:           result,
 		currentTime:      0,
 		textPosition:     0,
-		propertyStack:    []*AudioProperties{ap.copyProperties(ap.defaultProperties)},
+		propertyStack:    []*AudioProperties{baseProps},
 		plainTextBuilder: &strings.Builder{},
+		lexicons:         lexicons,
+		inferSpace:       speak.Whitespace == WhitespaceTrim,
+		runCtx:           runCtx,
+	}
+	if speak.Lang != "" {
+		ctx.languageScopes++
 	}
 
 	// 处理所有内容
 	for _, content := range speak.Content {
 		ctx.processContent(content)
 	}
+	if ctx.err != nil {
+		return nil, ctx.err
+	}
 
 	result.PlainText = ctx.plainTextBuilder.String()
 	result.TotalDuration = ctx.currentTime
@@ -93,6 +156,118 @@ func (ap *AudioProcessor) ProcessSSML(speak *Speak) (*AudioProcessingResult, err
 	return result, nil
 }
 
+// baseProperties 返回 speak 元素作用域内的音频属性
+func (ap *AudioProcessor) baseProperties(speak *Speak) *AudioProperties {
+	props := ap.copyProperties(ap.defaultProperties)
+	if speak.Lang != "" {
+		props.Language = canonicalLanguage(speak.Lang)
+	}
+	if speak.OnLangFailure != "" {
+		props.OnLangFailure = speak.OnLangFailure
+	}
+	return props
+}
+
+// elementProperties 返回元素作用域内的音频属性，不改变属性的元素直接返回 parent
+func (ap *AudioProcessor) elementProperties(parent *AudioProperties, element interface{}) *AudioProperties {
+	props := ap.copyProperties(parent)
+
+	switch elem := element.(type) {
+	case *Voice:
+		if elem.Name != "" {
+			props.Voice = elem.Name
+		}
+		if elem.Gender != "" {
+			props.Gender = elem.Gender
+		}
+		if elem.Languages != "" {
+			props.Language = canonicalLanguageList(elem.Languages)
+		}
+		if elem.OnLangFailure != "" {
+			props.OnLangFailure = elem.OnLangFailure
+		}
+	case *Lang:
+		if elem.Lang != "" {
+			props.Language = canonicalLanguage(elem.Lang)
+		}
+		if elem.OnLangFailure != "" {
+			props.OnLangFailure = elem.OnLangFailure
+		}
+	case *MSTTSExpressAs:
+		if elem.Style != "" {
+			props.Style = elem.Style
+		}
+		if elem.StyleDegree != "" {
+			props.StyleDegree = elem.StyleDegree
+		}
+		if elem.Role != "" {
+			props.Role = elem.Role
+		}
+	case *AmazonEffect:
+		if elem.Name != "" {
+			props.Effect = elem.Name
+		}
+		if elem.Phonation != "" {
+			props.Phonation = elem.Phonation
+		}
+		if elem.VocalTractLength != "" {
+			props.VocalTractLength = elem.VocalTractLength
+		}
+	case *AmazonDomain:
+		if elem.Name != "" {
+			props.Domain = elem.Name
+		}
+	case *Prosody:
+		if elem.Rate != "" {
+			props.Rate = elem.Rate
+		}
+		if elem.Pitch != "" {
+			props.Pitch = elem.Pitch
+		}
+		if elem.Volume != "" {
+			props.Volume = elem.Volume
+		}
+	case *Emphasis:
+		props.Emphasis = elem.Level
+	case *Phoneme:
+		props.Alphabet = elem.Alphabet
+		props.Phoneme = elem.Ph
+	case *SayAs:
+		props.InterpretAs = elem.InterpretAs
+		props.Format = elem.Format
+		props.Detail = elem.Detail
+	default:
+		return parent
+	}
+
+	return props
+}
+
+// resolveLexicons 解析 speak 中声明的词典，返回 xml:id -> 词典
+func (ap *AudioProcessor) resolveLexicons(runCtx context.Context, speak *Speak) (map[string]*PronunciationLexicon, error) {
+	lexicons := make(map[string]*PronunciationLexicon)
+	if ap.lexicons == nil {
+		return lexicons, nil
+	}
+
+	for _, item := range speak.Content {
+		declaration, ok := item.(*Lexicon)
+		if !ok {
+			continue
+		}
+		if err := runCtx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", formatAt(declaration.Start, "loading lexicon "+declaration.URI+" cancelled"), err)
+		}
+		lexicon, err := ap.lexicons.ResolveContext(runCtx, declaration.URI)
+		if err != nil {
+			return nil, err
+		}
+		lexicons[declaration.ID] = lexicon
+	}
+
+	return lexicons, nil
+}
+
 // processingContext 处理上下文
 type processingContext struct {
 	processor        *AudioProcessor
@@ -101,6 +276,13 @@ type processingContext struct {
 	textPosition     int
 	propertyStack    []*AudioProperties
 	plainTextBuilder *strings.Builder
+	lexicons         map[string]*PronunciationLexicon
+	lookupStack      []*PronunciationLexicon
+	pendingSpace     bool            // 上一段文本之后有空白，下一段文本前需要词边界
+	inferSpace       bool            // 源文本中元素之间的空白已被丢弃，在相邻的单词之间推断词边界
+	languageScopes   int             // 声明了 xml:lang 的外层元素数量，为 0 时文本的语言来自默认配置
+	runCtx           context.Context // 调用方的上下文，处理每个节点前检查是否已取消
+	err              error           // 处理被取消时的错误，之后的内容不再处理
 }
 
 // processElement 处理单个元素
@@ -110,6 +292,8 @@ func (ctx *processingContext) processElement(element SSMLElement) {
 		ctx.processText(elem)
 	case *Break:
 		ctx.processBreak(elem)
+	case *Mark:
+		ctx.processMark(elem)
 	case *Audio:
 		ctx.processAudio(elem)
 	case *Voice:
@@ -122,6 +306,27 @@ func (ctx *processingContext) processElement(element SSMLElement) {
 		ctx.processPhoneme(elem)
 	case *Sub:
 		ctx.processSub(elem)
+	case *SayAs:
+		ctx.processSayAs(elem)
+	case *Lookup:
+		ctx.processLookup(elem)
+	case *Lang:
+		ctx.processLang(elem)
+	case *MSTTSExpressAs:
+		ctx.processMSTTSExpressAs(elem)
+	case *AmazonEffect:
+		ctx.processAmazonEffect(elem)
+	case *AmazonDomain:
+		ctx.processAmazonDomain(elem)
+	case *MSTTSSilence:
+		// 句首句尾静音由 TTS 引擎处理
+	case *Meta, *Metadata, *Desc:
+		// 元数据和音频描述不参与合成
+	case *UnknownElement:
+		// 未知元素只保留其内容
+		for _, content := range elem.Content {
+			ctx.processContent(content)
+		}
 	case *Paragraph, *Sentence, *W:
 		ctx.processContainer(elem)
 	}
@@ -131,10 +336,75 @@ func (ctx *processingContext) processElement(element SSMLElement) {
 func (ctx *processingContext) processText(text *Text) {
 	content := strings.TrimSpace(text.Content)
 	if content == "" {
+		ctx.pendingSpace = ctx.pendingSpace || text.Content != ""
 		return
 	}
+	if content[0] != text.Content[0] {
+		ctx.pendingSpace = true
+	}
+
+	if len(ctx.lookupStack) > 0 {
+		ctx.processLexiconText(content)
+	} else {
+		ctx.addTextSegment(content, ctx.getCurrentProperties())
+	}
+
+	if content[len(content)-1] != text.Content[len(text.Content)-1] {
+		ctx.pendingSpace = true
+	}
+}
+
+// processLexiconText 在 lookup 作用域内处理文本，应用词典中的 alias 和 phoneme
+func (ctx *processingContext) processLexiconText(content string) {
+	// 内层 lookup 的词典优先，声明了 xml:lang 的词典只用于该语言范围内的文本；
+	// 文档没有为文本声明语言时，默认语言只是猜测，所有词典都适用
+	language := ctx.getCurrentProperties().Language
+	lexicons := make([]*PronunciationLexicon, 0, len(ctx.lookupStack))
+	for i := len(ctx.lookupStack) - 1; i >= 0; i-- {
+		if lexicon := ctx.lookupStack[i]; lexicon.Lang == "" || ctx.languageScopes == 0 || languageMatches(lexicon.Lang, language) {
+			lexicons = append(lexicons, lexicon)
+		}
+	}
+
+	plainStart := 0
+	for pos := 0; pos < len(content); {
+		match, ok := findLexeme(content, pos, lexicons)
+		if !ok {
+			_, size := utf8.DecodeRuneInString(content[pos:])
+			pos += size
+			continue
+		}
+
+		if plainStart < match.start {
+			ctx.addTextSegment(content[plainStart:match.start], ctx.getCurrentProperties())
+		}
+
+		grapheme := content[match.start:match.end]
+		props := ctx.getCurrentProperties()
+		switch {
+		case len(match.lexeme.Aliases) > 0:
+			ctx.addTextSegment(match.lexeme.Aliases[0], props)
+		case len(match.lexeme.Phonemes) > 0:
+			props.Alphabet = match.lexeme.Phonemes[0].Alphabet
+			props.Phoneme = match.lexeme.Phonemes[0].Ph
+			ctx.addTextSegment(grapheme, props)
+		default:
+			ctx.addTextSegment(grapheme, props)
+		}
+
+		pos = match.end
+		plainStart = pos
+	}
+
+	if plainStart < len(content) {
+		ctx.addTextSegment(content[plainStart:], ctx.getCurrentProperties())
+	}
+}
 
+// addTextSegment 添加一个文本音频片段
+func (ctx *processingContext) addTextSegment(content string, props *AudioProperties) {
 	// 添加到纯文本
+	ctx.writeWordBoundary(content)
 	ctx.plainTextBuilder.WriteString(content)
 
 	// 计算预期持续时间
@@ -145,7 +415,7 @@ func (ctx *processingContext) processText(text *Text) {
 		Text:       content,
 		StartTime:  ctx.currentTime,
 		Duration:   duration,
-		Properties: ctx.getCurrentProperties(),
+		Properties: props,
 	}
 
 	ctx.result.Segments = append(ctx.result.Segments, segment)
@@ -153,13 +423,67 @@ func (ctx *processingContext) processText(text *Text) {
 	ctx.textPosition += len(content)
 }
 
+// writeWordBoundary 在以空格分词的相邻文本之间写入空格，CJK 等文字之间不加空格；
+// 保留了空白的文档只在源文本有空白处写入，丢弃了空白的文档还会在相邻的单词之间写入
+func (ctx *processingContext) writeWordBoundary(next string) {
+	spaced := ctx.pendingSpace
+	ctx.pendingSpace = false
+
+	plain := ctx.plainTextBuilder.String()
+	if plain == "" || next == "" {
+		return
+	}
+
+	prev, _ := utf8.DecodeLastRuneInString(plain)
+	first, _ := utf8.DecodeRuneInString(next)
+	if unicode.IsSpace(prev) || unicode.IsSpace(first) || isUnspacedRune(prev) || isUnspacedRune(first) {
+		return
+	}
+	// 没有空白时，只在丢弃了空白的文档中两个单词之间或句读之后插入
+	if !spaced && (!ctx.inferSpace || !isWordRune(first) || !(isWordRune(prev) || strings.ContainsRune(".,!?;:)", prev))) {
+		return
+	}
+
+	ctx.plainTextBuilder.WriteByte(' ')
+	ctx.textPosition++
+}
+
+// isWordRune 判断字符是否为字母或数字
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isUnspacedRune 判断字符是否属于不以空格分词的文字（中日文、泰文等）或全角标点
+func isUnspacedRune(r rune) bool {
+	if r >= 0x3000 && r <= 0x303F || r >= 0xFF00 && r <= 0xFFEF {
+		return true
+	}
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar)
+}
+
 // processBreak 处理停顿
 func (ctx *processingContext) processBreak(br *Break) {
+	duration := breakDuration(br)
+
+	// 添加停顿指令
+	instruction := AudioInstruction{
+		Type:      "break",
+		Position:  ctx.textPosition,
+		StartTime: ctx.currentTime,
+		Duration:  duration,
+	}
+
+	ctx.result.Instructions = append(ctx.result.Instructions, instruction)
+	ctx.currentTime += duration
+}
+
+// breakDuration 返回停顿的时长：优先使用 time，其次按 strength 取默认时长
+func breakDuration(br *Break) time.Duration {
 	var duration time.Duration
 
 	if br.Time != "" {
 		// 解析时间格式（如 "1s", "500ms"）
-		if d, err := ctx.parseDuration(br.Time); err == nil {
+		if d, err := parseTimeValue(br.Time); err == nil {
 			duration = d
 		}
 	} else if br.Strength != "" {
@@ -184,15 +508,19 @@ func (ctx *processingContext) processBreak(br *Break) {
 		duration = 500 * time.Millisecond // 默认停顿
 	}
 
-	// 添加停顿指令
+	return duration
+}
+
+// processMark 处理书签
+func (ctx *processingContext) processMark(mark *Mark) {
 	instruction := AudioInstruction{
-		Type:     "break",
-		Position: ctx.textPosition,
-		Duration: duration,
+		Type:      "mark",
+		Position:  ctx.textPosition,
+		StartTime: ctx.currentTime,
+		MarkName:  mark.Name,
 	}
 
 	ctx.result.Instructions = append(ctx.result.Instructions, instruction)
-	ctx.currentTime += duration
 }
 
 // processAudio 处理音频插入
@@ -201,6 +529,7 @@ func (ctx *processingContext) processAudio(audio *Audio) {
 	instruction := AudioInstruction{
 		Type:      "audio",
 		Position:  ctx.textPosition,
+		StartTime: ctx.currentTime,
 		AudioFile: audio.Src,
 	}
 
@@ -214,20 +543,12 @@ func (ctx *processingContext) processAudio(audio *Audio) {
 
 // processVoice 处理声音变化
 func (ctx *processingContext) processVoice(voice *Voice) {
-	// 创建新的属性
-	newProps := ctx.copyCurrentProperties()
-	if voice.Name != "" {
-		newProps.Voice = voice.Name
-	}
-	if voice.Gender != "" {
-		newProps.Gender = voice.Gender
-	}
+	ctx.pushElementProperties(voice)
 	if voice.Languages != "" {
-		newProps.Language = voice.Languages
+		ctx.languageScopes++
+		defer func() { ctx.languageScopes-- }()
 	}
 
-	ctx.pushProperties(newProps)
-
 	// 处理子元素
 	for _, content := range voice.Content {
 		ctx.processContent(content)
@@ -236,21 +557,61 @@ func (ctx *processingContext) processVoice(voice *Voice) {
 	ctx.popProperties()
 }
 
-// processProsody 处理韵律
-func (ctx *processingContext) processProsody(prosody *Prosody) {
-	newProps := ctx.copyCurrentProperties()
+// processLang 处理语言切换，只改变语言，不改变声音
+func (ctx *processingContext) processLang(lang *Lang) {
+	ctx.pushElementProperties(lang)
+	if lang.Lang != "" {
+		ctx.languageScopes++
+		defer func() { ctx.languageScopes-- }()
+	}
+
+	// 处理子元素
+	for _, content := range lang.Content {
+		ctx.processContent(content)
+	}
 
-	if prosody.Rate != "" {
-		newProps.Rate = prosody.Rate
+	ctx.popProperties()
+}
+
+// processMSTTSExpressAs 处理说话风格
+func (ctx *processingContext) processMSTTSExpressAs(expressAs *MSTTSExpressAs) {
+	ctx.pushElementProperties(expressAs)
+
+	// 处理子元素
+	for _, content := range expressAs.Content {
+		ctx.processContent(content)
 	}
-	if prosody.Pitch != "" {
-		newProps.Pitch = prosody.Pitch
+
+	ctx.popProperties()
+}
+
+// processAmazonEffect 处理语音效果
+func (ctx *processingContext) processAmazonEffect(effect *AmazonEffect) {
+	ctx.pushElementProperties(effect)
+
+	// 处理子元素
+	for _, content := range effect.Content {
+		ctx.processContent(content)
 	}
-	if prosody.Volume != "" {
-		newProps.Volume = prosody.Volume
+
+	ctx.popProperties()
+}
+
+// processAmazonDomain 处理说话领域
+func (ctx *processingContext) processAmazonDomain(domain *AmazonDomain) {
+	ctx.pushElementProperties(domain)
+
+	// 处理子元素
+	for _, content := range domain.Content {
+		ctx.processContent(content)
 	}
 
-	ctx.pushProperties(newProps)
+	ctx.popProperties()
+}
+
+// processProsody 处理韵律
+func (ctx *processingContext) processProsody(prosody *Prosody) {
+	ctx.pushElementProperties(prosody)
 
 	// 处理子元素
 	for _, content := range prosody.Content {
@@ -262,10 +623,7 @@ func (ctx *processingContext) processProsody(prosody *Prosody) {
 
 // processEmphasis 处理强调
 func (ctx *processingContext) processEmphasis(emphasis *Emphasis) {
-	newProps := ctx.copyCurrentProperties()
-	newProps.Emphasis = emphasis.Level
-
-	ctx.pushProperties(newProps)
+	ctx.pushElementProperties(emphasis)
 
 	// 处理子元素
 	for _, content := range emphasis.Content {
@@ -277,10 +635,30 @@ func (ctx *processingContext) processEmphasis(emphasis *Emphasis) {
 
 // processPhoneme 处理发音
 func (ctx *processingContext) processPhoneme(phoneme *Phoneme) {
-	// 对于发音，我们使用原始文本，但可以添加发音指导信息
+	// 对于发音，我们使用原始文本，并把发音指导信息写入属性
+	ctx.pushElementProperties(phoneme)
+
 	for _, content := range phoneme.Content {
 		ctx.processContent(content)
 	}
+
+	ctx.popProperties()
+}
+
+// processLookup 处理词典作用域
+func (ctx *processingContext) processLookup(lookup *Lookup) {
+	lexicon, ok := ctx.lexicons[lookup.Ref]
+	if ok {
+		ctx.lookupStack = append(ctx.lookupStack, lexicon)
+	}
+
+	for _, content := range lookup.Content {
+		ctx.processContent(content)
+	}
+
+	if ok {
+		ctx.lookupStack = ctx.lookupStack[:len(ctx.lookupStack)-1]
+	}
 }
 
 // processSub 处理文本替换
@@ -290,10 +668,26 @@ func (ctx *processingContext) processSub(sub *Sub) {
 	ctx.processText(aliasText)
 }
 
+// processSayAs 处理读法提示
+func (ctx *processingContext) processSayAs(sayAs *SayAs) {
+	ctx.pushElementProperties(sayAs)
+
+	// 处理子元素
+	for _, content := range sayAs.Content {
+		ctx.processContent(content)
+	}
+
+	ctx.popProperties()
+}
+
 // processContainer 处理容器元素
 func (ctx *processingContext) processContainer(element SSMLElement) {
 	content := element.GetContent()
 
+	// 段落、句子和单词的边界也是词边界
+	ctx.pendingSpace = true
+	defer func() { ctx.pendingSpace = true }()
+
 	switch element.(type) {
 	case *Paragraph:
 		// 处理段落内容
@@ -324,11 +718,6 @@ func (ctx *processingContext) getCurrentProperties() *AudioProperties {
 	return ctx.copyProperties(ctx.propertyStack[len(ctx.propertyStack)-1])
 }
 
-// copyCurrentProperties 复制当前属性
-func (ctx *processingContext) copyCurrentProperties() *AudioProperties {
-	return ctx.copyProperties(ctx.propertyStack[len(ctx.propertyStack)-1])
-}
-
 // copyProperties 复制属性
 func (ap *AudioProcessor) copyProperties(props *AudioProperties) *AudioProperties {
 	return &AudioProperties{
@@ -339,6 +728,22 @@ func (ap *AudioProcessor) copyProperties(props *AudioProperties) *AudioPropertie
 		Gender:   props.Gender,
 		Language: props.Language,
 		Emphasis: props.Emphasis,
+
+		InterpretAs: props.InterpretAs,
+		Format:      props.Format,
+		Detail:      props.Detail,
+		Alphabet:    props.Alphabet,
+		Phoneme:     props.Phoneme,
+
+		OnLangFailure: props.OnLangFailure,
+
+		Style:            props.Style,
+		StyleDegree:      props.StyleDegree,
+		Role:             props.Role,
+		Effect:           props.Effect,
+		Phonation:        props.Phonation,
+		VocalTractLength: props.VocalTractLength,
+		Domain:           props.Domain,
 	}
 }
 
@@ -352,6 +757,12 @@ func (ctx *processingContext) pushProperties(props *AudioProperties) {
 	ctx.propertyStack = append(ctx.propertyStack, props)
 }
 
+// pushElementProperties 压入元素作用域内的属性
+func (ctx *processingContext) pushElementProperties(element interface{}) {
+	parent := ctx.propertyStack[len(ctx.propertyStack)-1]
+	ctx.pushProperties(ctx.processor.elementProperties(parent, element))
+}
+
 // popProperties 弹出属性栈
 func (ctx *processingContext) popProperties() {
 	if len(ctx.propertyStack) > 1 {
@@ -388,6 +799,10 @@ func (ctx *processingContext) getRateMultiplier(rate string) float64 {
 		// 尝试解析百分比或数值
 		if strings.HasSuffix(rate, "%") {
 			if val, err := strconv.ParseFloat(strings.TrimSuffix(rate, "%"), 64); err == nil {
+				// "+10%"、"-20%" 是相对于默认语速的变化
+				if rate[0] == '+' || rate[0] == '-' {
+					val += 100
+				}
 				return val / 100.0
 			}
 		}
@@ -395,25 +810,6 @@ func (ctx *processingContext) getRateMultiplier(rate string) float64 {
 	}
 }
 
-// parseDuration 解析时间字符串
-func (ctx *processingContext) parseDuration(timeStr string) (time.Duration, error) {
-	timeStr = strings.TrimSpace(timeStr)
-
-	if strings.HasSuffix(timeStr, "ms") {
-		val := strings.TrimSuffix(timeStr, "ms")
-		if ms, err := strconv.ParseFloat(val, 64); err == nil {
-			return time.Duration(ms) * time.Millisecond, nil
-		}
-	} else if strings.HasSuffix(timeStr, "s") {
-		val := strings.TrimSuffix(timeStr, "s")
-		if s, err := strconv.ParseFloat(val, 64); err == nil {
-			return time.Duration(s * float64(time.Second)), nil
-		}
-	}
-
-	return time.ParseDuration(timeStr)
-}
-
 // addAutomaticBreak 添加自动停顿（避免重复添加）
 func (ctx *processingContext) addAutomaticBreak(duration time.Duration) {
 	// 检查最后一个指令是否已经是停顿，避免重复
@@ -426,9 +822,10 @@ func (ctx *processingContext) addAutomaticBreak(duration time.Duration) {
 	}
 
 	instruction := AudioInstruction{
-		Type:     "auto_break",
-		Position: ctx.textPosition,
-		Duration: duration,
+		Type:      "auto_break",
+		Position:  ctx.textPosition,
+		StartTime: ctx.currentTime,
+		Duration:  duration,
 	}
 
 	ctx.result.Instructions = append(ctx.result.Instructions, instruction)
@@ -462,6 +859,17 @@ func (result *AudioProcessingResult) GetAudioInstructions() []AudioInstruction {
 	return audios
 }
 
+// GetMarkInstructions 获取所有书签指令
+func (result *AudioProcessingResult) GetMarkInstructions() []AudioInstruction {
+	var marks []AudioInstruction
+	for _, instruction := range result.Instructions {
+		if instruction.Type == "mark" {
+			marks = append(marks, instruction)
+		}
+	}
+	return marks
+}
+
 // FormatProcessingReport 生成处理报告
 func (result *AudioProcessingResult) FormatProcessingReport() string {
 	var report strings.Builder
@@ -500,6 +908,8 @@ func (result *AudioProcessingResult) FormatProcessingReport() string {
 				report.WriteString(fmt.Sprintf(" (时长: %v)", instruction.Duration))
 			case "audio":
 				report.WriteString(fmt.Sprintf(" (文件: %s)", instruction.AudioFile))
+			case "mark":
+				report.WriteString(fmt.Sprintf(" (名称: %s, 时间: %v)", instruction.MarkName, instruction.StartTime))
 			}
 			report.WriteString("\n")
 		}
@@ -510,10 +920,21 @@ func (result *AudioProcessingResult) FormatProcessingReport() string {
 
 // processContent 处理内容项（可能是 Text 或 SSMLElement）
 func (ctx *processingContext) processContent(content interface{}) {
+	if ctx.err != nil {
+		return
+	}
+	if err := ctx.runCtx.Err(); err != nil {
+		msg := fmt.Sprintf("processing cancelled after %d segments at %v", len(ctx.result.Segments), ctx.currentTime)
+		ctx.err = fmt.Errorf("%s: %w", formatAt(spanOf(content).Start, msg), err)
+		return
+	}
+
 	if text, ok := content.(Text); ok {
 		ctx.processText(&text)
 	} else if text, ok := content.(*Text); ok {
 		ctx.processText(text)
+	} else if custom, ok := content.(CustomElement); ok {
+		ctx.processCustom(custom)
 	} else if elem, ok := content.(SSMLElement); ok {
 		ctx.processElement(elem)
 	}
@@ -1,6 +1,7 @@
 package ssml
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -11,6 +12,14 @@ type AudioData struct {
 	Channels   int           // 声道数
 	Duration   time.Duration // 持续时间
 	Data       []float64     // 音频数据（归一化的浮点数）
+	Marks      []AudioMark   // 书签在音频中的位置
+}
+
+// AudioMark 表示书签在最终音频中的位置
+type AudioMark struct {
+	Name         string        // 书签名称
+	SampleOffset int           // 采样点偏移
+	Time         time.Duration // 时间偏移
 }
 
 // AudioPostProcessor 音频后处理器
@@ -25,8 +34,19 @@ func NewAudioPostProcessor(sampleRate int) *AudioPostProcessor {
 	}
 }
 
-// ProcessTTSAudio 处理 TTS 生成的音频
+// ProcessTTSAudio 处理 TTS 生成的音频。不知道合成文本，停顿之前复制与停顿等长的语音，
+// 书签位于插入时已复制的语音之后；需要按文本定位时使用 ProcessTTSAudioWithText
 func (app *AudioPostProcessor) ProcessTTSAudio(ttsAudio *AudioData, instructions []AudioInstruction) (*AudioData, error) {
+	return app.processTTSAudio(ttsAudio, instructions, 0)
+}
+
+// ProcessTTSAudioWithText 处理 TTS 生成的音频，按文本位置占合成文本的比例把停顿和书签定位到语音中
+func (app *AudioPostProcessor) ProcessTTSAudioWithText(ttsAudio *AudioData, instructions []AudioInstruction, plainText string) (*AudioData, error) {
+	return app.processTTSAudio(ttsAudio, instructions, len(plainText))
+}
+
+// processTTSAudio 处理 TTS 生成的音频，textLength 为合成文本的字节长度，未知时为 0
+func (app *AudioPostProcessor) processTTSAudio(ttsAudio *AudioData, instructions []AudioInstruction, textLength int) (*AudioData, error) {
 	if len(instructions) == 0 {
 		return ttsAudio, nil
 	}
@@ -41,27 +61,37 @@ func (app *AudioPostProcessor) ProcessTTSAudio(ttsAudio *AudioData, instructions
 	// 按位置排序指令
 	sortedInstructions := app.sortInstructionsByPosition(instructions)
 
+	// currentSample 为已复制的 TTS 语音的位置，停顿和书签都从这里开始定位
 	currentSample := 0
 
 	for _, instruction := range sortedInstructions {
 		switch instruction.Type {
 		case "break", "auto_break":
-			// 复制到停顿位置的音频
-			breakPosition := app.timeToSamples(instruction.Duration, ttsAudio.SampleRate)
-			if currentSample < len(ttsAudio.Data) {
-				endSample := currentSample + breakPosition
-				if endSample > len(ttsAudio.Data) {
-					endSample = len(ttsAudio.Data)
-				}
-				result.Data = append(result.Data, ttsAudio.Data[currentSample:endSample]...)
-				currentSample = endSample
+			// 复制到停顿位置的语音：已知合成文本时按文本位置定位，否则复制与停顿等长的语音
+			endSample := currentSample + app.timeToSamples(instruction.Duration, ttsAudio.SampleRate)
+			if textLength > 0 {
+				endSample = app.textPositionToSample(instruction.Position, textLength, len(ttsAudio.Data))
 			}
+			currentSample = app.copySpeech(result, ttsAudio, currentSample, endSample)
 
 			// 插入静音
 			silenceSamples := app.timeToSamples(instruction.Duration, ttsAudio.SampleRate)
 			silence := make([]float64, silenceSamples)
 			result.Data = append(result.Data, silence...)
 
+		case "mark":
+			// 按文本位置比例估算书签在 TTS 音频中的位置，先复制书签之前的语音
+			if textLength > 0 {
+				markSample := app.textPositionToSample(instruction.Position, textLength, len(ttsAudio.Data))
+				currentSample = app.copySpeech(result, ttsAudio, currentSample, markSample)
+			}
+
+			result.Marks = append(result.Marks, AudioMark{
+				Name:         instruction.MarkName,
+				SampleOffset: len(result.Data),
+				Time:         app.samplesToTime(len(result.Data), ttsAudio.SampleRate),
+			})
+
 		case "audio":
 			// 这里可以插入外部音频文件
 			// 目前只是添加一个占位符
@@ -79,6 +109,27 @@ func (app *AudioPostProcessor) ProcessTTSAudio(ttsAudio *AudioData, instructions
 	return result, nil
 }
 
+// textPositionToSample 按文本位置占合成文本的比例估算在 TTS 音频中的采样点
+func (app *AudioPostProcessor) textPositionToSample(position, textLength, samples int) int {
+	sample := int(int64(position) * int64(samples) / int64(textLength))
+	if sample > samples {
+		sample = samples
+	}
+	return sample
+}
+
+// copySpeech 将 TTS 音频中 from 到 to 的语音复制到结果中，返回新的复制位置
+func (app *AudioPostProcessor) copySpeech(result, ttsAudio *AudioData, from, to int) int {
+	if to > len(ttsAudio.Data) {
+		to = len(ttsAudio.Data)
+	}
+	if to <= from {
+		return from
+	}
+	result.Data = append(result.Data, ttsAudio.Data[from:to]...)
+	return to
+}
+
 // InsertSilence 在指定位置插入静音
 func (app *AudioPostProcessor) InsertSilence(audio *AudioData, position time.Duration, duration time.Duration) *AudioData {
 	positionSamples := app.timeToSamples(position, audio.SampleRate)
@@ -280,29 +331,43 @@ func NewCompleteSSMLToAudioProcessor(ttsAdapter TTSAdapter, sampleRate int) *Com
 	}
 }
 
-// ProcessSSMLToAudio 将 SSML 转换为处理后的音频
+// SetLexiconRegistry 设置用于解析 lexicon 的词典注册表
+func (processor *CompleteSSMLToAudioProcessor) SetLexiconRegistry(registry *LexiconRegistry) {
+	processor.audioProcessor.SetLexiconRegistry(registry)
+}
+
+// ProcessSSMLToAudio 将 SSML 转换为处理后的音频，停顿和书签按文本位置定位，见 ProcessTTSAudioWithText
 func (processor *CompleteSSMLToAudioProcessor) ProcessSSMLToAudio(ssmlContent string) (*AudioData, *AudioProcessingResult, error) {
+	return processor.ProcessSSMLToAudioContext(context.Background(), ssmlContent)
+}
+
+// ProcessSSMLToAudioContext 将 SSML 转换为处理后的音频，ctx 取消时在当前阶段停止
+func (processor *CompleteSSMLToAudioProcessor) ProcessSSMLToAudioContext(ctx context.Context, ssmlContent string) (*AudioData, *AudioProcessingResult, error) {
 	// 1. 解析 SSML
-	parseResult, err := processor.ssmlParser.Parse(ssmlContent)
+	parseResult, err := processor.ssmlParser.ParseContext(ctx, ssmlContent)
 	if err != nil {
 		return nil, nil, fmt.Errorf("SSML 解析失败: %w", err)
 	}
 
 	// 2. 处理 SSML，提取文本和指令
-	audioResult, err := processor.audioProcessor.ProcessSSML(parseResult.Root)
+	audioResult, err := processor.audioProcessor.ProcessSSMLContext(ctx, parseResult.Root)
 	if err != nil {
 		return nil, nil, fmt.Errorf("音频处理失败: %w", err)
 	}
 
 	// 检查是否有文本内容
 	if audioResult.PlainText == "" {
-		// 如果没有文本，创建一个空的音频数据
-		return &AudioData{
+		// 如果没有文本，创建一个空的音频数据，所有书签都位于开头
+		emptyAudio := &AudioData{
 			SampleRate: 44100,
 			Channels:   1,
 			Duration:   0,
 			Data:       []float64{},
-		}, audioResult, nil
+		}
+		for _, mark := range audioResult.GetMarkInstructions() {
+			emptyAudio.Marks = append(emptyAudio.Marks, AudioMark{Name: mark.MarkName})
+		}
+		return emptyAudio, audioResult, nil
 	}
 
 	// 3. 使用 TTS 生成基础音频
@@ -310,25 +375,23 @@ func (processor *CompleteSSMLToAudioProcessor) ProcessSSMLToAudio(ssmlContent st
 	if len(audioResult.Segments) > 0 {
 		properties = audioResult.Segments[0].Properties
 	} else {
-		// 使用默认属性
-		properties = &AudioProperties{
-			Rate:     "medium",
-			Pitch:    "medium",
-			Volume:   "medium",
-			Voice:    "default",
-			Gender:   "neutral",
-			Language: "zh-CN",
-			Emphasis: "none",
-		}
+		// 使用 speak 作用域内的属性，语言取自 xml:lang
+		properties = processor.audioProcessor.baseProperties(parseResult.Root)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("TTS 生成前已取消: %w", err)
+	}
 	ttsAudio, err := processor.ttsAdapter.GenerateAudio(audioResult.GetTextForTTS(), properties)
 	if err != nil {
 		return nil, nil, fmt.Errorf("TTS 生成失败: %w", err)
 	}
 
 	// 4. 应用后处理（插入静音等）
-	finalAudio, err := processor.postProcessor.ProcessTTSAudio(ttsAudio, audioResult.Instructions)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("音频后处理前已取消: %w", err)
+	}
+	finalAudio, err := processor.postProcessor.ProcessTTSAudioWithText(ttsAudio, audioResult.Instructions, audioResult.PlainText)
 	if err != nil {
 		return nil, nil, fmt.Errorf("音频后处理失败: %w", err)
 	}
@@ -1,5 +1,7 @@
 package ssml
 
+import "encoding/xml"
+
 // Builder SSML 构建器
 type Builder struct {
 	speak *Speak
@@ -26,12 +28,30 @@ func (b *Builder) Lang(lang string) *Builder {
 	return b
 }
 
+// OnLangFailure 设置语言不受支持时的处理方式
+func (b *Builder) OnLangFailure(value string) *Builder {
+	b.speak.OnLangFailure = value
+	return b
+}
+
+// Namespace 在 speak 元素上声明命名空间前缀
+func (b *Builder) Namespace(prefix, uri string) *Builder {
+	b.speak.Namespaces = append(b.speak.Namespaces, Namespace{Prefix: prefix, URI: uri})
+	return b
+}
+
 // Text 添加文本内容
 func (b *Builder) Text(text string) *Builder {
 	b.speak.Content = append(b.speak.Content, Text{Content: text})
 	return b
 }
 
+// Fragment 添加片段中内容节点的副本，通常来自 Parser.ParseFragment；复制规则与 InsertContent 相同
+func (b *Builder) Fragment(content []interface{}) *Builder {
+	b.speak.Content = append(b.speak.Content, copyContent(content)...)
+	return b
+}
+
 // Audio 添加音频元素
 func (b *Builder) Audio(src string, fallbackText string) *Builder {
 	audio := &Audio{Src: src}
@@ -83,6 +103,44 @@ func (b *Builder) EmphasisText(level string, text string) *Builder {
 	})
 }
 
+// Lexicon 声明发音词典
+func (b *Builder) Lexicon(uri, id string) *Builder {
+	b.speak.Content = append(b.speak.Content, &Lexicon{URI: uri, ID: id})
+	return b
+}
+
+// Lookup 添加词典作用域
+func (b *Builder) Lookup(ref string, builderFunc func(*ElementBuilder)) *Builder {
+	lookup := &Lookup{Ref: ref}
+
+	if builderFunc != nil {
+		elementBuilder := &ElementBuilder{}
+		builderFunc(elementBuilder)
+		lookup.Content = elementBuilder.content
+	}
+
+	b.speak.Content = append(b.speak.Content, lookup)
+	return b
+}
+
+// Mark 添加书签元素
+func (b *Builder) Mark(name string) *Builder {
+	b.speak.Content = append(b.speak.Content, &Mark{Name: name})
+	return b
+}
+
+// Meta 添加元信息
+func (b *Builder) Meta(name, content string) *Builder {
+	b.speak.Content = append(b.speak.Content, &Meta{Name: name, Value: content})
+	return b
+}
+
+// Metadata 添加元数据，innerXML 原样保留
+func (b *Builder) Metadata(innerXML string) *Builder {
+	b.speak.Content = append(b.speak.Content, &Metadata{InnerXML: innerXML})
+	return b
+}
+
 // Paragraph 添加段落元素
 func (b *Builder) Paragraph(builderFunc func(*ElementBuilder)) *Builder {
 	paragraph := &Paragraph{}
@@ -232,6 +290,31 @@ func (b *Builder) SubText(alias, text string) *Builder {
 	})
 }
 
+// SayAs 添加读法元素
+func (b *Builder) SayAs(interpretAs, format, detail string, builderFunc func(*ElementBuilder)) *Builder {
+	sayAs := &SayAs{
+		InterpretAs: interpretAs,
+		Format:      format,
+		Detail:      detail,
+	}
+
+	if builderFunc != nil {
+		elementBuilder := &ElementBuilder{}
+		builderFunc(elementBuilder)
+		sayAs.Content = elementBuilder.content
+	}
+
+	b.speak.Content = append(b.speak.Content, sayAs)
+	return b
+}
+
+// SayAsText 添加读法文本
+func (b *Builder) SayAsText(interpretAs, format, text string) *Builder {
+	return b.SayAs(interpretAs, format, "", func(eb *ElementBuilder) {
+		eb.Text(text)
+	})
+}
+
 // Voice 添加声音元素
 func (b *Builder) Voice(gender, age, variant, name, lang string, builderFunc func(*ElementBuilder)) *Builder {
 	voice := &Voice{
@@ -292,6 +375,126 @@ func (b *Builder) WText(role, text string) *Builder {
 	})
 }
 
+// Token 添加 token 元素（SSML 1.1 中 w 的别名）
+func (b *Builder) Token(role string, builderFunc func(*ElementBuilder)) *Builder {
+	token := &W{XMLName: xml.Name{Local: "token"}, Role: role}
+
+	if builderFunc != nil {
+		elementBuilder := &ElementBuilder{}
+		builderFunc(elementBuilder)
+		token.Content = elementBuilder.content
+	}
+
+	b.speak.Content = append(b.speak.Content, token)
+	return b
+}
+
+// Language 添加语言元素，切换语言但不切换声音
+func (b *Builder) Language(lang string, builderFunc func(*ElementBuilder)) *Builder {
+	langElem := &Lang{Lang: lang}
+
+	if builderFunc != nil {
+		elementBuilder := &ElementBuilder{}
+		builderFunc(elementBuilder)
+		langElem.Content = elementBuilder.content
+	}
+
+	b.speak.Content = append(b.speak.Content, langElem)
+	return b
+}
+
+// LanguageText 添加指定语言的文本
+func (b *Builder) LanguageText(lang, text string) *Builder {
+	return b.Language(lang, func(eb *ElementBuilder) {
+		eb.Text(text)
+	})
+}
+
+// ExpressAs 添加 mstts:express-as 说话风格
+func (b *Builder) ExpressAs(style, styleDegree, role string, builderFunc func(*ElementBuilder)) *Builder {
+	expressAs := &MSTTSExpressAs{
+		XMLName:     xml.Name{Space: MSTTSNamespace, Local: "express-as"},
+		Style:       style,
+		StyleDegree: styleDegree,
+		Role:        role,
+	}
+
+	if builderFunc != nil {
+		elementBuilder := &ElementBuilder{}
+		builderFunc(elementBuilder)
+		expressAs.Content = elementBuilder.content
+	}
+
+	b.speak.Content = append(b.speak.Content, expressAs)
+	return b
+}
+
+// ExpressAsText 添加指定说话风格的文本
+func (b *Builder) ExpressAsText(style, text string) *Builder {
+	return b.ExpressAs(style, "", "", func(eb *ElementBuilder) {
+		eb.Text(text)
+	})
+}
+
+// Silence 添加 mstts:silence 静音
+func (b *Builder) Silence(type_, value string) *Builder {
+	silence := &MSTTSSilence{
+		XMLName: xml.Name{Space: MSTTSNamespace, Local: "silence"},
+		Type:    type_,
+		Value:   value,
+	}
+	b.speak.Content = append(b.speak.Content, silence)
+	return b
+}
+
+// Effect 添加 amazon:effect 语音效果
+func (b *Builder) Effect(name string, builderFunc func(*ElementBuilder)) *Builder {
+	effect := &AmazonEffect{
+		XMLName: xml.Name{Space: AmazonNamespace, Local: "effect"},
+		Name:    name,
+	}
+
+	if builderFunc != nil {
+		elementBuilder := &ElementBuilder{}
+		builderFunc(elementBuilder)
+		effect.Content = elementBuilder.content
+	}
+
+	b.speak.Content = append(b.speak.Content, effect)
+	return b
+}
+
+// EffectText 添加带语音效果的文本
+func (b *Builder) EffectText(name, text string) *Builder {
+	return b.Effect(name, func(eb *ElementBuilder) {
+		eb.Text(text)
+	})
+}
+
+// Domain 添加 amazon:domain 说话领域
+func (b *Builder) Domain(name string, builderFunc func(*ElementBuilder)) *Builder {
+	domain := &AmazonDomain{
+		XMLName: xml.Name{Space: AmazonNamespace, Local: "domain"},
+		Name:    name,
+	}
+
+	if builderFunc != nil {
+		elementBuilder := &ElementBuilder{}
+		builderFunc(elementBuilder)
+		domain.Content = elementBuilder.content
+	}
+
+	b.speak.Content = append(b.speak.Content, domain)
+	return b
+}
+
+// DomainText 添加指定说话领域的文本
+func (b *Builder) DomainText(name, text string) *Builder {
+	return b.Domain(name, func(eb *ElementBuilder) {
+		eb.Text(text)
+	})
+}
+
 // Build 构建 SSML
 func (b *Builder) Build() *Speak {
 	return b.speak
@@ -314,6 +517,12 @@ func (eb *ElementBuilder) Text(text string) *ElementBuilder {
 	return eb
 }
 
+// Fragment 添加片段中内容节点的副本，通常来自 Parser.ParseFragment；复制规则与 InsertContent 相同
+func (eb *ElementBuilder) Fragment(content []interface{}) *ElementBuilder {
+	eb.content = append(eb.content, copyContent(content)...)
+	return eb
+}
+
 // Audio 添加音频
 func (eb *ElementBuilder) Audio(src string, fallbackText string) *ElementBuilder {
 	audio := &Audio{Src: src}
@@ -344,6 +553,16 @@ func (eb *ElementBuilder) BreakStrength(strength string) *ElementBuilder {
 	return eb.Break("", strength)
 }
 
+// Desc 添加音频描述
+func (eb *ElementBuilder) Desc(lang, text string) *ElementBuilder {
+	desc := &Desc{
+		Lang:    lang,
+		Content: []interface{}{Text{Content: text}},
+	}
+	eb.content = append(eb.content, desc)
+	return eb
+}
+
 // Emphasis 添加强调
 func (eb *ElementBuilder) Emphasis(level string, builderFunc func(*ElementBuilder)) *ElementBuilder {
 	emphasis := &Emphasis{Level: level}
@@ -365,6 +584,26 @@ func (eb *ElementBuilder) EmphasisText(level string, text string) *ElementBuilde
 	})
 }
 
+// Lookup 添加词典作用域
+func (eb *ElementBuilder) Lookup(ref string, builderFunc func(*ElementBuilder)) *ElementBuilder {
+	lookup := &Lookup{Ref: ref}
+
+	if builderFunc != nil {
+		nestedBuilder := &ElementBuilder{}
+		builderFunc(nestedBuilder)
+		lookup.Content = nestedBuilder.content
+	}
+
+	eb.content = append(eb.content, lookup)
+	return eb
+}
+
+// Mark 添加书签
+func (eb *ElementBuilder) Mark(name string) *ElementBuilder {
+	eb.content = append(eb.content, &Mark{Name: name})
+	return eb
+}
+
 // Phoneme 添加发音
 func (eb *ElementBuilder) Phoneme(alphabet, ph string, builderFunc func(*ElementBuilder)) *ElementBuilder {
 	phoneme := &Phoneme{
@@ -429,6 +668,31 @@ func (eb *ElementBuilder) SubText(alias, text string) *ElementBuilder {
 	})
 }
 
+// SayAs 添加读法
+func (eb *ElementBuilder) SayAs(interpretAs, format, detail string, builderFunc func(*ElementBuilder)) *ElementBuilder {
+	sayAs := &SayAs{
+		InterpretAs: interpretAs,
+		Format:      format,
+		Detail:      detail,
+	}
+
+	if builderFunc != nil {
+		nestedBuilder := &ElementBuilder{}
+		builderFunc(nestedBuilder)
+		sayAs.Content = nestedBuilder.content
+	}
+
+	eb.content = append(eb.content, sayAs)
+	return eb
+}
+
+// SayAsText 添加读法文本
+func (eb *ElementBuilder) SayAsText(interpretAs, format, text string) *ElementBuilder {
+	return eb.SayAs(interpretAs, format, "", func(nested *ElementBuilder) {
+		nested.Text(text)
+	})
+}
+
 // Voice 添加声音
 func (eb *ElementBuilder) Voice(gender, age, variant, name, lang string, builderFunc func(*ElementBuilder)) *ElementBuilder {
 	voice := &Voice{
@@ -462,3 +726,95 @@ func (eb *ElementBuilder) W(role string, builderFunc func(*ElementBuilder)) *Ele
 	eb.content = append(eb.content, w)
 	return eb
 }
+
+// Token 添加 token（SSML 1.1 中 w 的别名）
+func (eb *ElementBuilder) Token(role string, builderFunc func(*ElementBuilder)) *ElementBuilder {
+	token := &W{XMLName: xml.Name{Local: "token"}, Role: role}
+
+	if builderFunc != nil {
+		nestedBuilder := &ElementBuilder{}
+		builderFunc(nestedBuilder)
+		token.Content = nestedBuilder.content
+	}
+
+	eb.content = append(eb.content, token)
+	return eb
+}
+
+// Language 添加语言切换
+func (eb *ElementBuilder) Language(lang string, builderFunc func(*ElementBuilder)) *ElementBuilder {
+	langElem := &Lang{Lang: lang}
+
+	if builderFunc != nil {
+		nestedBuilder := &ElementBuilder{}
+		builderFunc(nestedBuilder)
+		langElem.Content = nestedBuilder.content
+	}
+
+	eb.content = append(eb.content, langElem)
+	return eb
+}
+
+// ExpressAs 添加 mstts:express-as 说话风格
+func (eb *ElementBuilder) ExpressAs(style, styleDegree, role string, builderFunc func(*ElementBuilder)) *ElementBuilder {
+	expressAs := &MSTTSExpressAs{
+		XMLName:     xml.Name{Space: MSTTSNamespace, Local: "express-as"},
+		Style:       style,
+		StyleDegree: styleDegree,
+		Role:        role,
+	}
+
+	if builderFunc != nil {
+		nestedBuilder := &ElementBuilder{}
+		builderFunc(nestedBuilder)
+		expressAs.Content = nestedBuilder.content
+	}
+
+	eb.content = append(eb.content, expressAs)
+	return eb
+}
+
+// Silence 添加 mstts:silence 静音
+func (eb *ElementBuilder) Silence(type_, value string) *ElementBuilder {
+	silence := &MSTTSSilence{
+		XMLName: xml.Name{Space: MSTTSNamespace, Local: "silence"},
+		Type:    type_,
+		Value:   value,
+	}
+	eb.content = append(eb.content, silence)
+	return eb
+}
+
+// Effect 添加 amazon:effect 语音效果
+func (eb *ElementBuilder) Effect(name string, builderFunc func(*ElementBuilder)) *ElementBuilder {
+	effect := &AmazonEffect{
+		XMLName: xml.Name{Space: AmazonNamespace, Local: "effect"},
+		Name:    name,
+	}
+
+	if builderFunc != nil {
+		nestedBuilder := &ElementBuilder{}
+		builderFunc(nestedBuilder)
+		effect.Content = nestedBuilder.content
+	}
+
+	eb.content = append(eb.content, effect)
+	return eb
+}
+
+// Domain 添加 amazon:domain 说话领域
+func (eb *ElementBuilder) Domain(name string, builderFunc func(*ElementBuilder)) *ElementBuilder {
+	domain := &AmazonDomain{
+		XMLName: xml.Name{Space: AmazonNamespace, Local: "domain"},
+		Name:    name,
+	}
+
+	if builderFunc != nil {
+		nestedBuilder := &ElementBuilder{}
+		builderFunc(nestedBuilder)
+		domain.Content = nestedBuilder.content
+	}
+
+	eb.content = append(eb.content, domain)
+	return eb
+}
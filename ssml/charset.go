@@ -0,0 +1,145 @@
+package ssml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// CharsetReader 返回将 charset 编码的输入转换为 UTF-8 的 Reader，可用作 xml.Decoder.CharsetReader。
+// 支持 WHATWG 编码标准中的名称，如 GBK、GB2312、GB18030、Big5、Shift_JIS、EUC-KR、UTF-16LE/BE；
+// 无法解码的字节替换为 U+FFFD
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// lookupEncoding 按名称查找编码
+func lookupEncoding(charset string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "utf-16", "utf16":
+		// XML 中没有 BOM 的 UTF-16 按大端处理，WHATWG 中的 utf-16 则是小端
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM), nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc, nil
+}
+
+// isUTF8 判断编码名称是否表示 UTF-8
+func isUTF8(charset string) bool {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8":
+		return true
+	}
+	return false
+}
+
+// memoryReader 是 strings.Reader 和 bytes.Reader 共有的方法，不需要 bufio 就能读取开头的字节，
+// 也可以直接交给 xml.Decoder
+type memoryReader interface {
+	io.ReaderAt
+	io.ByteReader
+	io.Seeker
+	Len() int
+	Size() int64
+}
+
+// sniffEncoding 根据 BOM 或 UTF-16 的 "<?" 判断输入的编码，去掉 UTF-8 BOM，
+// 并把 UTF-16 输入转换为 UTF-8；转换后声明中的编码不再适用，此时 converted 为 true
+func sniffEncoding(reader io.Reader) (decoded io.Reader, converted bool) {
+	var head []byte
+	memory, inMemory := reader.(memoryReader)
+	if inMemory {
+		head = make([]byte, 4)
+		n, _ := memory.ReadAt(head, memory.Size()-int64(memory.Len()))
+		head = head[:n]
+	} else {
+		buffered := bufio.NewReader(reader)
+		head, _ = buffered.Peek(4)
+		reader = buffered
+	}
+
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		if inMemory {
+			memory.Seek(3, io.SeekCurrent)
+		} else {
+			reader.(*bufio.Reader).Discard(3)
+		}
+		return reader, false
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case bytes.Equal(head, []byte{'<', 0, '?', 0}):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case bytes.Equal(head, []byte{0, '<', 0, '?'}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	default:
+		return reader, false
+	}
+	return transform.NewReader(reader, enc.NewDecoder()), true
+}
+
+// charsetReaderFor 返回解码器使用的 CharsetReader；输入已转换为 UTF-8 时忽略声明中的编码
+func charsetReaderFor(converted bool) func(string, io.Reader) (io.Reader, error) {
+	if converted {
+		return func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	}
+	return CharsetReader
+}
+
+// encodingDecl 匹配 XML 声明中的 encoding
+var encodingDecl = regexp.MustCompile(`^\s*<\?xml[^>]*?\bencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// decodeDocument 将整个文档转换为 UTF-8：先根据 BOM 判断，再根据 XML 声明中的编码
+func decodeDocument(data []byte) ([]byte, error) {
+	reader, converted := sniffEncoding(bytes.NewReader(data))
+	data, err := io.ReadAll(reader)
+	if err != nil || converted {
+		return data, err
+	}
+
+	match := encodingDecl.FindSubmatch(data)
+	if match == nil || isUTF8(string(match[1])) {
+		return data, nil
+	}
+	enc, err := lookupEncoding(string(match[1]))
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Bytes(data)
+}
+
+// encodeOutput 将 UTF-8 文本转换为 charset 编码，目标编码无法表示的字符写为数字字符引用
+func encodeOutput(text, charset string) ([]byte, error) {
+	if isUTF8(charset) {
+		return []byte(text), nil
+	}
+
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToLower(charset), "utf-16") {
+		// UTF-16 可以表示所有字符
+		return enc.NewEncoder().Bytes([]byte(text))
+	}
+	return encoding.HTMLEscapeUnsupported(enc.NewEncoder()).Bytes([]byte(text))
+}
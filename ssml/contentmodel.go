@@ -0,0 +1,472 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// inlineElements 大多数容器元素都允许的子元素
+var inlineElements = []string{"audio", "break", "emphasis", "lang", "lookup", "mark", "phoneme", "prosody", "say-as", "sub", "voice", "w"}
+
+// contentModel SSML 1.1 中每个元素允许的子元素，文本在所有容器元素中都允许；
+// say-as、sub、phoneme、desc 只包含文本，break、mark、lexicon、meta 为空元素
+var contentModel = map[string]map[string]bool{
+	"speak":    elementSet(inlineElements, "p", "s", "lexicon", "meta", "metadata"),
+	"p":        elementSet(inlineElements, "s"),
+	"s":        elementSet(inlineElements),
+	"voice":    elementSet(inlineElements, "p", "s"),
+	"prosody":  elementSet(inlineElements, "p", "s"),
+	"lang":     elementSet(inlineElements, "p", "s"),
+	"lookup":   elementSet(inlineElements, "p", "s"),
+	"audio":    elementSet(inlineElements, "p", "s", "desc"),
+	"emphasis": elementSet(inlineElements),
+	"w":        elementSet([]string{"audio", "break", "emphasis", "mark", "phoneme", "prosody", "say-as", "sub"}),
+	"say-as":   {},
+	"sub":      {},
+	"phoneme":  {},
+	"desc":     {},
+}
+
+// headElements 只能出现在 speak 开头的元素
+var headElements = map[string]bool{"lexicon": true, "meta": true, "metadata": true}
+
+// elementSet 创建元素名集合
+func elementSet(names []string, more ...string) map[string]bool {
+	set := make(map[string]bool, len(names)+len(more))
+	for _, name := range names {
+		set[name] = true
+	}
+	for _, name := range more {
+		set[name] = true
+	}
+	return set
+}
+
+// modelScope 祖先元素的状态：p 和 s 不能通过 voice 等元素间接嵌套在 p 或 s 中
+type modelScope struct {
+	inParagraph bool
+	inSentence  bool
+}
+
+// enter 返回进入 name 元素之后的状态
+func (s modelScope) enter(name string) modelScope {
+	switch name {
+	case "p":
+		s.inParagraph = true
+	case "s":
+		s.inSentence = true
+	}
+	return s
+}
+
+// allowedIn 判断 child 元素能否出现在 parent 中，parent 为空时不限制
+func allowedIn(parent, child string, scope modelScope) bool {
+	if child == "p" && (scope.inParagraph || scope.inSentence) {
+		return false
+	}
+	if child == "s" && scope.inSentence {
+		return false
+	}
+	if parent == "" {
+		return true
+	}
+	return contentModel[parent][child]
+}
+
+// modelName 返回节点在内容模型中的名称；文本为 "#text"，厂商元素、未知元素和自定义元素为空，不检查
+func modelName(node interface{}) string {
+	switch node.(type) {
+	case Text, *Text:
+		return "#text"
+	case *Speak:
+		return "speak"
+	case *Paragraph:
+		return "p"
+	case *Sentence:
+		return "s"
+	case *W:
+		return "w"
+	case *Voice:
+		return "voice"
+	case *Prosody:
+		return "prosody"
+	case *Emphasis:
+		return "emphasis"
+	case *Lang:
+		return "lang"
+	case *Lookup:
+		return "lookup"
+	case *Audio:
+		return "audio"
+	case *SayAs:
+		return "say-as"
+	case *Sub:
+		return "sub"
+	case *Phoneme:
+		return "phoneme"
+	case *Desc:
+		return "desc"
+	case *Break:
+		return "break"
+	case *Mark:
+		return "mark"
+	case *Lexicon:
+		return "lexicon"
+	case *Meta:
+		return "meta"
+	case *Metadata:
+		return "metadata"
+	}
+	return ""
+}
+
+// validateContentModel 按 SSML 1.1 的元素层次验证内容，parent 为空时不检查顶层节点（用于片段）；
+// 开启 Restructure 且不是严格模式时，随后调整不合法的嵌套，返回调整后的内容
+func (p *Parser) validateContentModel(parent string, content []interface{}, severity Severity, result *ParseResult) ([]interface{}, error) {
+	var firstErr error
+	p.checkContentModel(parent, modelScope{}.enter(parent), content, severity, result, &firstErr)
+
+	if !p.config.Restructure || p.config.StrictMode {
+		return content, firstErr
+	}
+	content = p.restructure(parent, modelScope{}.enter(parent), content)
+	if parent == "speak" {
+		content = moveHeadElements(content)
+	}
+	return content, firstErr
+}
+
+// checkContentModel 检查 parent 中的每个节点并递归检查子节点
+func (p *Parser) checkContentModel(parent string, scope modelScope, content []interface{}, severity Severity, result *ParseResult, firstErr *error) {
+	bodyStarted := false
+	for _, item := range content {
+		name := modelName(item)
+		if name == "#text" {
+			if strings.TrimSpace(textOf(item)) != "" {
+				bodyStarted = true
+			}
+			continue
+		}
+
+		element, ok := item.(SSMLElement)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			// 厂商元素和未知元素的子节点按所在的位置检查
+			p.checkContentModel("", scope, element.GetContent(), severity, result, firstErr)
+			bodyStarted = true
+			continue
+		}
+
+		span := spanOf(item)
+		switch {
+		case parent != "" && !allowedIn(parent, name, scope):
+			p.reportContent(result, Diagnostic{
+				Code:     CodeInvalidNesting,
+				Severity: severity,
+				Message:  nestingMessage(parent, name, scope),
+				Span:     span,
+				Node:     item,
+				Fix:      fmt.Sprintf("move <%s> out of <%s>", name, parent),
+			}, firstErr)
+		case parent == "speak" && headElements[name] && bodyStarted:
+			p.reportContent(result, Diagnostic{
+				Code:     CodeMisplacedContent,
+				Severity: severity,
+				Message:  fmt.Sprintf("<%s> must appear before other content in <speak>", name),
+				Span:     span,
+				Node:     item,
+				Fix:      fmt.Sprintf("move <%s> to the start of <speak>", name),
+			}, firstErr)
+		}
+		if !headElements[name] {
+			bodyStarted = true
+		}
+
+		p.checkContentModel(name, scope.enter(name), element.GetContent(), severity, result, firstErr)
+	}
+}
+
+// nestingMessage 返回不合法嵌套的说明
+func nestingMessage(parent, child string, scope modelScope) string {
+	allowed := contentModel[parent]
+	switch {
+	case allowed != nil && len(allowed) == 0:
+		return fmt.Sprintf("<%s> is not allowed inside <%s>, which only contains text", child, parent)
+	case allowed[child] && scope.inSentence:
+		return fmt.Sprintf("<%s> is not allowed inside <%s> within a sentence", child, parent)
+	case allowed[child]:
+		return fmt.Sprintf("<%s> is not allowed inside <%s> within a paragraph", child, parent)
+	}
+	return fmt.Sprintf("<%s> is not allowed inside <%s>", child, parent)
+}
+
+// reportContent 报告内容模型的问题，并记录第一个错误
+func (p *Parser) reportContent(result *ParseResult, d Diagnostic, firstErr *error) {
+	p.report(result, d)
+	if *firstErr == nil && d.Severity == SeverityError {
+		*firstErr = fmt.Errorf("%s: %s", d.Span.Start, d.Message)
+	}
+}
+
+// restructure 调整 parent 中的内容：拆分包含不合法子元素的元素，把子元素移到外层，
+// 只包含文本的元素中的子元素替换为其文本；返回的节点中仍可能有不能留在 parent 中的元素，由外层继续调整
+func (p *Parser) restructure(parent string, scope modelScope, content []interface{}) []interface{} {
+	var adjusted []interface{}
+	for _, item := range content {
+		element, ok := item.(SSMLElement)
+		name := modelName(item)
+		if !ok || name == "#text" {
+			adjusted = append(adjusted, item)
+			continue
+		}
+		if name == "" {
+			element.SetContent(p.restructure("", scope, element.GetContent()))
+			adjusted = append(adjusted, item)
+			continue
+		}
+		adjusted = append(adjusted, p.splitElement(element, name, scope)...)
+	}
+	return adjusted
+}
+
+// splitElement 调整元素的子节点；有不合法的子元素时在其前后拆分元素，把子元素移到外层，返回替换该元素的节点
+func (p *Parser) splitElement(element SSMLElement, name string, scope modelScope) []interface{} {
+	inner := scope.enter(name)
+	content := p.restructure(name, inner, element.GetContent())
+
+	if allowed, ok := contentModel[name]; ok && len(allowed) == 0 {
+		element.SetContent(flattenText(content))
+		return []interface{}{element}
+	}
+	// w 是一个词，拆分会改变发音，不合法的子元素替换为其内容
+	if name == "w" {
+		element.SetContent(unwrapDisallowed(name, inner, content))
+		return []interface{}{element}
+	}
+
+	var pieces, current []interface{}
+	split := false
+	flush := func() {
+		if hasSpeakableContent(current) {
+			piece := element
+			if split {
+				piece = cloneElement(element)
+			}
+			piece.SetContent(current)
+			pieces = append(pieces, piece)
+			split = true
+		}
+		current = nil
+	}
+	moved := false
+	for _, child := range content {
+		childName := modelName(child)
+		if childName != "" && childName != "#text" && !allowedIn(name, childName, inner) {
+			flush()
+			// 移出 voice、prosody 等元素的 p 和 s 仍需要这些元素的属性，在其内部重建外层元素
+			if name != "p" && name != "s" && (childName == "p" || childName == "s") {
+				hoisted := child.(SSMLElement)
+				hoisted.SetContent(wrapContent(element, name, hoisted.GetContent()))
+			}
+			pieces = append(pieces, child)
+			moved = true
+			continue
+		}
+		current = append(current, child)
+	}
+	if !moved {
+		element.SetContent(content)
+		return []interface{}{element}
+	}
+	flush()
+	return pieces
+}
+
+// wrapContent 用 wrapper 的副本包裹 content 中的节点；wrapper 中不允许的 p 和 s 不被包裹，在其内部继续包裹
+func wrapContent(wrapper SSMLElement, name string, content []interface{}) []interface{} {
+	var wrapped, run []interface{}
+	flush := func() {
+		if hasSpeakableContent(run) {
+			piece := cloneElement(wrapper)
+			piece.SetContent(run)
+			wrapped = append(wrapped, piece)
+		} else {
+			wrapped = append(wrapped, run...)
+		}
+		run = nil
+	}
+	for _, item := range content {
+		itemName := modelName(item)
+		if (itemName == "p" || itemName == "s") && !contentModel[name][itemName] {
+			flush()
+			element := item.(SSMLElement)
+			element.SetContent(wrapContent(wrapper, name, element.GetContent()))
+			wrapped = append(wrapped, item)
+			continue
+		}
+		run = append(run, item)
+	}
+	flush()
+	return wrapped
+}
+
+// unwrapDisallowed 把 parent 中不允许的子元素替换为其内容，直到剩下的节点都允许出现在 parent 中
+func unwrapDisallowed(parent string, scope modelScope, content []interface{}) []interface{} {
+	unwrapped := make([]interface{}, 0, len(content))
+	for _, item := range content {
+		name := modelName(item)
+		if name == "" || name == "#text" || allowedIn(parent, name, scope) {
+			unwrapped = append(unwrapped, item)
+			continue
+		}
+		if element, ok := item.(SSMLElement); ok {
+			unwrapped = append(unwrapped, unwrapDisallowed(parent, scope, element.GetContent())...)
+		}
+	}
+	return unwrapped
+}
+
+// moveHeadElements 把 lexicon、meta、metadata 移到 speak 开头，保持其余节点的顺序
+func moveHeadElements(content []interface{}) []interface{} {
+	var head, body []interface{}
+	for _, item := range content {
+		if headElements[modelName(item)] {
+			head = append(head, item)
+		} else {
+			body = append(body, item)
+		}
+	}
+	return append(head, body...)
+}
+
+// flattenText 把子元素替换为其中的文本
+func flattenText(content []interface{}) []interface{} {
+	flattened := make([]interface{}, 0, len(content))
+	for _, item := range content {
+		if modelName(item) == "#text" {
+			flattened = append(flattened, item)
+			continue
+		}
+		if element, ok := item.(SSMLElement); ok {
+			var text strings.Builder
+			Walk(element, VisitorFuncs{OnEnter: func(cursor *Cursor) {
+				if node := cursor.Node(); modelName(node) == "#text" {
+					text.WriteString(textOf(node))
+				}
+			}})
+			if text.Len() > 0 {
+				flattened = append(flattened, Text{Content: text.String(), Span: spanOf(item)})
+			}
+		}
+	}
+	return flattened
+}
+
+// hasSpeakableContent 判断内容中是否有元素或非空白文本
+func hasSpeakableContent(content []interface{}) bool {
+	for _, item := range content {
+		if modelName(item) != "#text" || strings.TrimSpace(textOf(item)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// textOf 返回文本节点的内容
+func textOf(node interface{}) string {
+	switch t := node.(type) {
+	case Text:
+		return t.Content
+	case *Text:
+		return t.Content
+	}
+	return ""
+}
+
+// cloneElement 复制元素本身（属性和位置），用于拆分元素；子节点由调用方设置
+func cloneElement(element SSMLElement) SSMLElement {
+	switch e := element.(type) {
+	case *Paragraph:
+		c := *e
+		return &c
+	case *Sentence:
+		c := *e
+		return &c
+	case *W:
+		c := *e
+		return &c
+	case *Voice:
+		c := *e
+		return &c
+	case *Prosody:
+		c := *e
+		return &c
+	case *Emphasis:
+		c := *e
+		return &c
+	case *Lang:
+		c := *e
+		return &c
+	case *Lookup:
+		c := *e
+		return &c
+	case *Audio:
+		c := *e
+		return &c
+	}
+	return element
+}
+
+// skipEmptyElement 跳过空元素到其结束标签；其中的文本和子元素被丢弃，记录到解码器中，解析结束后报告
+func (p *Parser) skipEmptyElement(decoder *tokenDecoder, start xml.StartElement, pos Position) error {
+	depth := 0
+	dropped := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			dropped = true
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" {
+				dropped = true
+			}
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if dropped {
+				decoder.dropped = append(decoder.dropped, Diagnostic{
+					Code:    CodeMisplacedContent,
+					Message: fmt.Sprintf("<%s> must be empty; its content was dropped", qualifiedName(start.Name)),
+					Span:    Span{Start: pos, End: decoder.position()},
+					Fix:     fmt.Sprintf("write <%s/> and move the content after it", qualifiedName(start.Name)),
+				})
+			}
+			return nil
+		}
+	}
+}
+
+// reportDropped 报告解析时从空元素中丢弃的内容，严格模式下为错误并返回第一个错误
+func (p *Parser) reportDropped(decoder *tokenDecoder, result *ParseResult) error {
+	severity := SeverityWarning
+	if p.config.StrictMode {
+		severity = SeverityError
+	}
+
+	var firstErr error
+	for _, d := range decoder.dropped {
+		d.Severity = severity
+		p.reportContent(result, d, &firstErr)
+	}
+	decoder.dropped = nil
+	return firstErr
+}
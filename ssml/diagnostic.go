@@ -0,0 +1,249 @@
+package ssml
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity 诊断级别
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+// String 返回诊断级别名称
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// DiagnosticCode 稳定的诊断代码，可用于过滤和抑制
+type DiagnosticCode string
+
+// 诊断代码
+const (
+	CodeMissingVersion     DiagnosticCode = "SSML001"
+	CodeMissingLang        DiagnosticCode = "SSML002"
+	CodeMaxNestingDepth    DiagnosticCode = "SSML003"
+	CodeXMLSyntax          DiagnosticCode = "SSML004"
+	CodeInvalidRoot        DiagnosticCode = "SSML005"
+	CodeMissingRoot        DiagnosticCode = "SSML006"
+	CodeElementParseError  DiagnosticCode = "SSML007"
+	CodeUnknownLexiconRef  DiagnosticCode = "SSML008"
+	CodeRepairedEntity     DiagnosticCode = "SSML009"
+	CodeEscapedMarkup      DiagnosticCode = "SSML010"
+	CodeAutoClosedElement  DiagnosticCode = "SSML011"
+	CodeStrayEndTag        DiagnosticCode = "SSML012"
+	CodeWrappedRoot        DiagnosticCode = "SSML013"
+	CodeMaxInputBytes      DiagnosticCode = "SSML014"
+	CodeMaxElements        DiagnosticCode = "SSML015"
+	CodeMaxTextLength      DiagnosticCode = "SSML016"
+	CodeMaxAttributes      DiagnosticCode = "SSML017"
+	CodeMaxBreakDuration   DiagnosticCode = "SSML018"
+	CodeCancelled          DiagnosticCode = "SSML019" // 不再报告：取消时解析直接返回 ctx.Err()
+	CodeMultipleRoots      DiagnosticCode = "SSML020"
+	CodeInvalidRecord      DiagnosticCode = "SSML021"
+	CodeInvalidEncoding    DiagnosticCode = "SSML022"
+	CodeInvalidAttribute   DiagnosticCode = "SSML023"
+	CodeMaxDuration        DiagnosticCode = "SSML024"
+	CodeLongBreak          DiagnosticCode = "SSML025"
+	CodeInvalidNesting     DiagnosticCode = "SSML026"
+	CodeMisplacedContent   DiagnosticCode = "SSML027"
+	CodeInvalidLanguage    DiagnosticCode = "SSML028"
+	CodeUnsupportedElement DiagnosticCode = "SSML029"
+	CodeUnsupportedValue   DiagnosticCode = "SSML030"
+	CodeVendorLimit        DiagnosticCode = "SSML031"
+	CodeTrailingContent    DiagnosticCode = "SSML032"
+	CodeUnconsumedElement  DiagnosticCode = "SSML033"
+	CodeUnquotedAttribute  DiagnosticCode = "SSML034"
+)
+
+// diagnosticNames 诊断代码对应的可读名称
+var diagnosticNames = map[DiagnosticCode]string{
+	CodeMissingVersion:     "missing-version",
+	CodeMissingLang:        "missing-lang",
+	CodeMaxNestingDepth:    "max-nesting-depth",
+	CodeXMLSyntax:          "xml-syntax",
+	CodeInvalidRoot:        "invalid-root",
+	CodeMissingRoot:        "missing-root",
+	CodeElementParseError:  "element-parse-error",
+	CodeUnknownLexiconRef:  "unknown-lexicon-ref",
+	CodeRepairedEntity:     "repaired-entity",
+	CodeEscapedMarkup:      "escaped-markup",
+	CodeAutoClosedElement:  "auto-closed-element",
+	CodeStrayEndTag:        "stray-end-tag",
+	CodeWrappedRoot:        "wrapped-root",
+	CodeMaxInputBytes:      "max-input-bytes",
+	CodeMaxElements:        "max-elements",
+	CodeMaxTextLength:      "max-text-length",
+	CodeMaxAttributes:      "max-attributes",
+	CodeMaxBreakDuration:   "max-break-duration",
+	CodeCancelled:          "cancelled",
+	CodeMultipleRoots:      "multiple-roots",
+	CodeInvalidRecord:      "invalid-record",
+	CodeInvalidEncoding:    "invalid-encoding",
+	CodeInvalidAttribute:   "invalid-attribute",
+	CodeMaxDuration:        "max-duration",
+	CodeLongBreak:          "long-break",
+	CodeInvalidNesting:     "invalid-nesting",
+	CodeMisplacedContent:   "misplaced-content",
+	CodeInvalidLanguage:    "invalid-language",
+	CodeUnsupportedElement: "unsupported-element",
+	CodeUnsupportedValue:   "unsupported-value",
+	CodeVendorLimit:        "vendor-limit",
+	CodeTrailingContent:    "trailing-content",
+	CodeUnconsumedElement:  "unconsumed-element",
+	CodeUnquotedAttribute:  "unquoted-attribute",
+}
+
+// Name 返回诊断代码的可读名称
+func (c DiagnosticCode) Name() string {
+	if name, ok := diagnosticNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Diagnostic 结构化的诊断信息
+type Diagnostic struct {
+	Code     DiagnosticCode
+	Severity Severity
+	Message  string
+	Span     Span        // 引发诊断的源码区间
+	Node     interface{} // 相关节点，可能为 nil
+	Fix      string      // 建议的修复方式，可能为空
+}
+
+// String 以 "行:列: 代码 名称: 消息" 的形式输出诊断
+func (d Diagnostic) String() string {
+	msg := fmt.Sprintf("%s %s: %s", d.Code, d.Code.Name(), d.Message)
+	return formatAt(d.Span.Start, msg)
+}
+
+// HasErrors 判断解析结果中是否有错误
+func (r *ParseResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// Diagnostics 返回所有诊断信息（错误在前，警告在后）
+func (r *ParseResult) Diagnostics() []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(r.Errors)+len(r.Warnings))
+	diagnostics = append(diagnostics, r.Errors...)
+	diagnostics = append(diagnostics, r.Warnings...)
+	return diagnostics
+}
+
+// DiagnosticsByCode 返回指定代码的诊断信息
+func (r *ParseResult) DiagnosticsByCode(code DiagnosticCode) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, d := range r.Diagnostics() {
+		if d.Code == code {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics
+}
+
+// CountByCode 按诊断代码统计数量
+func (r *ParseResult) CountByCode() map[DiagnosticCode]int {
+	counts := make(map[DiagnosticCode]int)
+	for _, d := range r.Diagnostics() {
+		counts[d.Code]++
+	}
+	return counts
+}
+
+// FormatDiagnostics 生成诊断报告，每行一条
+func (r *ParseResult) FormatDiagnostics() string {
+	var report strings.Builder
+	for _, d := range r.Diagnostics() {
+		report.WriteString(fmt.Sprintf("%s: %s\n", d.Severity, d))
+	}
+	return report.String()
+}
+
+// report 记录诊断信息，被抑制的代码会被忽略
+func (p *Parser) report(result *ParseResult, d Diagnostic) {
+	result.add(d, p.config.SuppressCodes)
+}
+
+// add 按严重程度记录诊断信息，代码在 suppress 中时忽略
+func (r *ParseResult) add(d Diagnostic, suppress []DiagnosticCode) {
+	for _, code := range suppress {
+		if code == d.Code {
+			return
+		}
+	}
+
+	if d.Severity == SeverityError {
+		r.Errors = append(r.Errors, d)
+	} else {
+		r.Warnings = append(r.Warnings, d)
+	}
+}
+
+// reportParseError 记录解析时的错误，位置为出错的 token，资源限制错误为超出限制的节点：
+// XML 语法错误使用 CodeXMLSyntax，资源限制错误使用其代码，其他错误使用 fallback。返回的 XML 语法错误带有 token 的位置；
+// 取消不是文档的问题，不记录诊断信息，直接返回 ctx.Err()
+func (p *Parser) reportParseError(decoder *tokenDecoder, err error, fallback DiagnosticCode, result *ParseResult) error {
+	if cerr := contextError(err); cerr != nil {
+		return cerr
+	}
+	span := Span{Start: decoder.tokenStart, End: decoder.position()}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) && limitErr.Pos.IsValid() {
+		span.Start = limitErr.Pos
+	}
+	code := errorCode(err, fallback)
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		code = CodeXMLSyntax
+		err = fmt.Errorf("%s: %w", span.Start, err)
+	}
+
+	message := fmt.Sprintf("Error parsing element: %v", err)
+	if code == CodeXMLSyntax {
+		message = fmt.Sprintf("XML parsing error: %v", err)
+	}
+	p.report(result, Diagnostic{
+		Code:     code,
+		Severity: SeverityError,
+		Message:  message,
+		Span:     span,
+	})
+	return err
+}
+
+// errorCode 返回错误对应的诊断代码：资源限制错误返回其代码，其他错误返回 fallback
+func errorCode(err error, fallback DiagnosticCode) DiagnosticCode {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return limitErr.Code
+	}
+	return fallback
+}
+
+// contextError 返回 err 中的 context.Canceled 或 context.DeadlineExceeded，err 不是取消时返回 nil
+func contextError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return context.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return context.DeadlineExceeded
+	}
+	return nil
+}
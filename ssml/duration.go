@@ -0,0 +1,86 @@
+package ssml
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// validateDuration 按 DurationCheck 估算文档时长，检查总时长和每个 break 的时长。
+// 超过 MaxBreakDuration 的 time 在拒绝模式下解析时即失败，这里检查按 strength 取默认时长的 break。
+// 估算在 ctx 取消时停止，无论检查模式都直接返回 ctx.Err()
+func (p *Parser) validateDuration(ctx context.Context, speak *Speak, result *ParseResult) error {
+	if p.config.DurationCheck == DurationCheckOff {
+		return nil
+	}
+	severity := SeverityWarning
+	if p.config.DurationCheck == DurationCheckReject {
+		severity = SeverityError
+	}
+
+	var firstErr error
+	if limit := p.config.MaxBreakDuration; limit > 0 {
+		Walk(speak, VisitorFuncs{OnEnter: func(cursor *Cursor) {
+			br, ok := cursor.Node().(*Break)
+			if !ok {
+				return
+			}
+			duration := breakDuration(br)
+			if duration <= limit {
+				return
+			}
+			p.report(result, Diagnostic{
+				Code:     CodeLongBreak,
+				Severity: severity,
+				Message:  fmt.Sprintf("Break of %v exceeds %v", duration, limit),
+				Span:     br.Span,
+				Node:     br,
+				Fix:      fmt.Sprintf(`shorten the break to at most %v or raise ValidationConfig.MaxBreakDuration`, limit),
+			})
+			if firstErr == nil && severity == SeverityError {
+				firstErr = fmt.Errorf("%s: break of %v exceeds %v: %w", br.Start, duration, limit, ErrDurationTooLong)
+			}
+		}})
+	}
+
+	processor := p.estimator
+	if processor == nil {
+		processor = NewAudioProcessor()
+	}
+	estimate, err := processor.ProcessSSMLContext(ctx, speak)
+	if cerr := contextError(err); cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		// 无法估算时不能确认文档在限制之内
+		p.report(result, Diagnostic{
+			Code:     CodeMaxDuration,
+			Severity: severity,
+			Message:  fmt.Sprintf("Cannot estimate the duration: %v", err),
+			Span:     speak.Span,
+			Node:     speak,
+			Fix:      "fix the error, for example a lexicon that cannot be loaded",
+		})
+		if firstErr == nil && severity == SeverityError {
+			firstErr = fmt.Errorf("estimating duration: %w", err)
+		}
+		return firstErr
+	}
+	result.Duration = estimate.TotalDuration
+
+	if limit := p.config.MaxDuration; limit > 0 && estimate.TotalDuration > limit {
+		p.report(result, Diagnostic{
+			Code:     CodeMaxDuration,
+			Severity: severity,
+			Message:  fmt.Sprintf("Estimated duration %v exceeds %v", estimate.TotalDuration.Round(time.Millisecond), limit),
+			Span:     speak.Span,
+			Node:     speak,
+			Fix:      "split the document or raise ValidationConfig.MaxDuration",
+		})
+		if firstErr == nil && severity == SeverityError {
+			firstErr = fmt.Errorf("estimated duration %v exceeds %v: %w", estimate.TotalDuration.Round(time.Millisecond), limit, ErrDurationTooLong)
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,239 @@
+package ssml
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// FragmentResult 片段解析结果
+type FragmentResult struct {
+	ParseResult               // 诊断信息，Root 始终为空
+	Content     []interface{} // 片段中的内容节点
+}
+
+// ParseFragment 解析不带 speak 根元素的 SSML 片段，如 `<prosody rate="slow">...</prosody><break time="1s"/>`
+func (p *Parser) ParseFragment(fragment string) (*FragmentResult, error) {
+	return p.ParseFragmentReaderContext(context.Background(), strings.NewReader(fragment))
+}
+
+// ParseFragmentContext 解析 SSML 片段，ctx 取消时停止解析
+func (p *Parser) ParseFragmentContext(ctx context.Context, fragment string) (*FragmentResult, error) {
+	return p.ParseFragmentReaderContext(ctx, strings.NewReader(fragment))
+}
+
+// ParseFragmentReader 从 Reader 解析 SSML 片段
+func (p *Parser) ParseFragmentReader(reader io.Reader) (*FragmentResult, error) {
+	return p.ParseFragmentReaderContext(context.Background(), reader)
+}
+
+// ParseFragmentReaderContext 从 Reader 解析 SSML 片段，ctx 取消时停止解析
+func (p *Parser) ParseFragmentReaderContext(ctx context.Context, reader io.Reader) (*FragmentResult, error) {
+	result := &FragmentResult{
+		ParseResult: ParseResult{
+			Warnings: []Diagnostic{},
+			Errors:   []Diagnostic{},
+		},
+	}
+
+	decoder, err := p.newDecoder(ctx, reader, &result.ParseResult, false)
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, p.reportParseError(decoder, err, CodeXMLSyntax, &result.ParseResult)
+		}
+
+		switch se := token.(type) {
+		case xml.StartElement:
+			pos := decoder.tokenStart
+			element, err := p.parseElement(decoder, se, pos)
+			if err != nil {
+				return result, p.reportParseError(decoder, err, CodeElementParseError, &result.ParseResult)
+			}
+			if element != nil {
+				result.Content = append(result.Content, element)
+			}
+
+		case xml.CharData:
+			if err := p.checkText(se, decoder.tokenStart); err != nil {
+				return result, p.reportParseError(decoder, err, CodeElementParseError, &result.ParseResult)
+			}
+			if text, ok := p.textNode(decoder, se); ok {
+				result.Content = append(result.Content, text)
+			}
+		}
+	}
+
+	// 片段中的 lookup 引用宿主文档声明的词典，这里只验证属性、语言标签、元素层次和嵌套深度；
+	// 片段插入的位置未知，顶层节点不检查
+	severity := SeverityWarning
+	if p.config.StrictMode {
+		severity = SeverityError
+	}
+	if err := p.reportDropped(decoder, &result.ParseResult); err != nil {
+		return result, err
+	}
+	if err := p.validateAttributes(result.Content, severity, &result.ParseResult); err != nil {
+		return result, err
+	}
+	if err := p.validateLanguages(result.Content, severity, &result.ParseResult); err != nil {
+		return result, err
+	}
+	content, err := p.validateContentModel("", result.Content, severity, &result.ParseResult)
+	result.Content = content
+	if err != nil {
+		return result, err
+	}
+	if err := p.validateNestingDepth(result.Content, 0, &result.ParseResult); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// InsertContent 将内容节点的副本插入到元素内容的 index 位置，index 超出范围时追加到末尾；
+// 同一个片段可以插入多处，之后修改片段不影响 element。自定义元素无法复制，插入的是原节点
+func InsertContent(element SSMLElement, index int, content []interface{}) {
+	existing := element.GetContent()
+	if index < 0 || index > len(existing) {
+		index = len(existing)
+	}
+
+	merged := make([]interface{}, 0, len(existing)+len(content))
+	merged = append(merged, existing[:index]...)
+	for _, node := range content {
+		merged = append(merged, copyNode(node))
+	}
+	merged = append(merged, existing[index:]...)
+	element.SetContent(merged)
+}
+
+// copyContent 深复制内容节点
+func copyContent(content []interface{}) []interface{} {
+	if content == nil {
+		return nil
+	}
+	copied := make([]interface{}, len(content))
+	for i, node := range content {
+		copied[i] = copyNode(node)
+	}
+	return copied
+}
+
+// copyNode 深复制节点及其属性和子节点；Text 是值类型直接返回，自定义元素原样返回
+func copyNode(node interface{}) interface{} {
+	switch n := node.(type) {
+	case *Text:
+		c := *n
+		return &c
+	case *Break:
+		c := *n
+		c.Attrs = copyAttrs(n.Attrs)
+		return &c
+	case *Mark:
+		c := *n
+		c.Attrs = copyAttrs(n.Attrs)
+		return &c
+	case *Lexicon:
+		c := *n
+		c.Attrs = copyAttrs(n.Attrs)
+		return &c
+	case *Meta:
+		c := *n
+		c.Attrs = copyAttrs(n.Attrs)
+		return &c
+	case *Metadata:
+		c := *n
+		c.Attrs = copyAttrs(n.Attrs)
+		return &c
+	case *MSTTSSilence:
+		c := *n
+		c.Attrs = copyAttrs(n.Attrs)
+		return &c
+	case *Audio:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Lookup:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Desc:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Emphasis:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Paragraph:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Phoneme:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Prosody:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Sentence:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Sub:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *SayAs:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Voice:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *Lang:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *W:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *MSTTSExpressAs:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *AmazonEffect:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *AmazonDomain:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	case *UnknownElement:
+		c := *n
+		c.Attrs, c.Content = copyAttrs(n.Attrs), copyContent(n.Content)
+		return &c
+	}
+	return node
+}
+
+// copyAttrs 复制属性列表
+func copyAttrs(attrs []xml.Attr) []xml.Attr {
+	if attrs == nil {
+		return nil
+	}
+	return append([]xml.Attr(nil), attrs...)
+}
@@ -0,0 +1,236 @@
+package ssml
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// languageCanon 语言标签的规范化方式：替换弃用和旧式的标签，宏语言替换为常用的语言，如 cmn -> zh
+const languageCanon = language.Macro | language.Legacy | language.Deprecated
+
+// maxCachedLanguages 缓存的语言标签的最大数量，避免不可信的输入使缓存无限增长
+const maxCachedLanguages = 4096
+
+// languageCache 缓存规范化的结果，同一标签的规范形式共用一个字符串
+var languageCache = struct {
+	sync.RWMutex
+	tags map[string]cachedLanguage
+}{tags: make(map[string]cachedLanguage)}
+
+// cachedLanguage 一个标签的规范化结果
+type cachedLanguage struct {
+	normalized string
+	err        error
+}
+
+// NormalizeLanguage 将 BCP-47 语言标签转换为规范形式：统一大小写和分隔符（zh_cn -> zh-CN），
+// 替换宏语言和弃用的子标签，并去掉与地区对应的默认文字（cmn-Hans-CN -> zh-CN，en-Latn-US -> en-US）
+func NormalizeLanguage(tag string) (string, error) {
+	languageCache.RLock()
+	cached, ok := languageCache.tags[tag]
+	languageCache.RUnlock()
+	if ok {
+		return cached.normalized, cached.err
+	}
+
+	normalized, err := normalizeLanguage(tag)
+	languageCache.Lock()
+	if len(languageCache.tags) < maxCachedLanguages {
+		languageCache.tags[tag] = cachedLanguage{normalized: normalized, err: err}
+	}
+	languageCache.Unlock()
+	return normalized, err
+}
+
+// normalizeLanguage 解析并规范化语言标签
+func normalizeLanguage(tag string) (string, error) {
+	trimmed := strings.TrimSpace(tag)
+	if trimmed == "" {
+		return "", fmt.Errorf("empty language tag")
+	}
+
+	parsed, err := languageCanon.Parse(strings.ReplaceAll(trimmed, "_", "-"))
+	if err != nil {
+		return "", fmt.Errorf("invalid language tag %q: %w", tag, err)
+	}
+	return minimizeScript(parsed).String(), nil
+}
+
+// minimizeScript 文字是语言和地区的默认文字时去掉文字子标签，使 zh-Hans-CN 与 zh-CN 相同
+func minimizeScript(tag language.Tag) language.Tag {
+	base, script, region := tag.Raw()
+	if script == (language.Script{}) || len(tag.Variants()) > 0 || len(tag.Extensions()) > 0 {
+		return tag
+	}
+
+	var withoutScript language.Tag
+	var err error
+	if region == (language.Region{}) {
+		withoutScript, err = language.Compose(base)
+	} else {
+		withoutScript, err = language.Compose(base, region)
+	}
+	if err != nil {
+		return tag
+	}
+	if likely, confidence := withoutScript.Script(); likely == script && confidence != language.No {
+		return withoutScript
+	}
+	return tag
+}
+
+// canonicalLanguage 返回规范化的语言标签，无法解析时返回去掉空白的原值
+func canonicalLanguage(tag string) string {
+	if normalized, err := NormalizeLanguage(tag); err == nil {
+		return normalized
+	}
+	return strings.TrimSpace(tag)
+}
+
+// canonicalLanguageList 规范化以空白分隔的语言标签列表，如 voice 的 xml:lang
+func canonicalLanguageList(tags string) string {
+	fields := strings.Fields(tags)
+	for i, tag := range fields {
+		fields[i] = canonicalLanguage(tag)
+	}
+	return strings.Join(fields, " ")
+}
+
+// MatchLanguage 按 RFC 4647 的 lookup 方式在 supported 中查找与 tag 匹配的语言：
+// 补全默认文字后先精确匹配，再依次去掉末尾的子标签回退，如 zh-HK -> zh-Hant-HK -> zh-Hant -> zh。
+// 比较时两边都按规范形式，返回 supported 中的原值，没有匹配时返回 false
+func MatchLanguage(tag string, supported []string) (string, bool) {
+	wanted, err := NormalizeLanguage(tag)
+	if err != nil {
+		return "", false
+	}
+
+	normalized := make([]string, len(supported))
+	for i, candidate := range supported {
+		normalized[i] = canonicalLanguage(candidate)
+	}
+
+	for candidate := maximizeScript(wanted); candidate != ""; candidate = truncateLanguage(candidate) {
+		canonical := canonicalLanguage(candidate)
+		for i, s := range normalized {
+			if strings.EqualFold(s, canonical) {
+				return supported[i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// maximizeScript 为没有文字子标签的标签补上默认文字，如 zh-HK -> zh-Hant-HK，使回退时能经过文字
+func maximizeScript(tag string) string {
+	parsed, err := language.Parse(tag)
+	if err != nil || len(parsed.Variants()) > 0 || len(parsed.Extensions()) > 0 {
+		return tag
+	}
+	base, _, region := parsed.Raw()
+	script, confidence := parsed.Script()
+	if confidence == language.No {
+		return tag
+	}
+
+	var maximized language.Tag
+	if region == (language.Region{}) {
+		maximized, err = language.Compose(base, script)
+	} else {
+		maximized, err = language.Compose(base, script, region)
+	}
+	if err != nil {
+		return tag
+	}
+	return maximized.String()
+}
+
+// languageMatches 判断 tags 中是否有语言在语言范围内，如范围 en 包含 en-US；都按规范形式比较
+func languageMatches(languageRange, tags string) bool {
+	languageRange = canonicalLanguage(languageRange)
+	for _, tag := range strings.Fields(tags) {
+		for candidate := canonicalLanguage(tag); candidate != ""; candidate = truncateLanguage(candidate) {
+			if strings.EqualFold(candidate, languageRange) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// truncateLanguage 去掉语言标签末尾的子标签，以及随之留在末尾的单字符扩展前缀
+func truncateLanguage(tag string) string {
+	index := strings.LastIndex(tag, "-")
+	if index < 0 {
+		return ""
+	}
+	tag = tag[:index]
+	if index = strings.LastIndex(tag, "-"); index >= 0 && len(tag)-index == 2 {
+		tag = tag[:index]
+	}
+	return tag
+}
+
+// validateLanguages 验证 nodes 及其子元素中 speak、voice、lang、desc 的 xml:lang 是否为合法的 BCP-47 标签
+func (p *Parser) validateLanguages(nodes []interface{}, severity Severity, result *ParseResult) error {
+	return p.newValidation(severity, result, (*validation).languages).walk(nodes)
+}
+
+// languages 验证节点的 xml:lang
+func (v *validation) languages(node interface{}) {
+	// 按空白拆分标签列表，不分配切片
+	rest := languageValue(node)
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" {
+			break
+		}
+		end := strings.IndexAny(rest, " \t\r\n")
+		if end < 0 {
+			end = len(rest)
+		}
+		v.fail(v.p.checkLanguage(node, rest[:end], v.severity, v.result))
+		rest = rest[end:]
+	}
+}
+
+// languageValue 返回节点的 xml:lang，voice 中可以是以空白分隔的列表
+func languageValue(node interface{}) string {
+	switch n := node.(type) {
+	case *Speak:
+		return n.Lang
+	case *Voice:
+		return n.Languages
+	case *Lang:
+		return n.Lang
+	case *Desc:
+		return n.Lang
+	}
+	return ""
+}
+
+// checkLanguage 验证单个语言标签；下划线分隔的标签不合法，但可以规范化，Fix 中给出规范形式
+func (p *Parser) checkLanguage(node interface{}, tag string, severity Severity, result *ParseResult) error {
+	normalized, err := NormalizeLanguage(tag)
+	if err == nil && !strings.Contains(tag, "_") {
+		return nil
+	}
+
+	fix := `use a BCP-47 tag such as "zh-CN" or "en-US"`
+	if err == nil {
+		fix = fmt.Sprintf("use %q", normalized)
+	}
+	element := elementName(node)
+	p.report(result, Diagnostic{
+		Code:     CodeInvalidLanguage,
+		Severity: severity,
+		Message:  fmt.Sprintf("Invalid language tag %q on <%s>", tag, element),
+		Span:     spanOf(node),
+		Node:     node,
+		Fix:      fix,
+	})
+	return fmt.Errorf("%s: invalid language tag %q on <%s>", spanOf(node).Start, tag, element)
+}
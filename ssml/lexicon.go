@@ -0,0 +1,314 @@
+package ssml
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PronunciationLexicon W3C PLS 发音词典
+type PronunciationLexicon struct {
+	Alphabet string
+	Lang     string
+	Lexemes  []Lexeme
+}
+
+// Lexeme 词典中的词条
+type Lexeme struct {
+	Graphemes []string
+	Phonemes  []LexemePhoneme
+	Aliases   []string
+}
+
+// LexemePhoneme 词条的发音
+type LexemePhoneme struct {
+	Alphabet string
+	Ph       string
+}
+
+// plsDocument PLS 文件的 XML 结构
+type plsDocument struct {
+	XMLName  xml.Name    `xml:"lexicon"`
+	Alphabet string      `xml:"alphabet,attr"`
+	Lang     string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Lexemes  []plsLexeme `xml:"lexeme"`
+}
+
+type plsLexeme struct {
+	Graphemes []string     `xml:"grapheme"`
+	Phonemes  []plsPhoneme `xml:"phoneme"`
+	Aliases   []string     `xml:"alias"`
+}
+
+type plsPhoneme struct {
+	Alphabet string `xml:"alphabet,attr"`
+	Ph       string `xml:",chardata"`
+}
+
+// ParsePLS 解析 PLS 发音词典
+func ParsePLS(reader io.Reader) (*PronunciationLexicon, error) {
+	var doc plsDocument
+	if err := xml.NewDecoder(reader).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid PLS document: %w", err)
+	}
+
+	lexicon := &PronunciationLexicon{
+		Alphabet: doc.Alphabet,
+		Lang:     doc.Lang,
+	}
+
+	for _, l := range doc.Lexemes {
+		lexeme := Lexeme{}
+		for _, g := range l.Graphemes {
+			if g = strings.TrimSpace(g); g != "" {
+				lexeme.Graphemes = append(lexeme.Graphemes, g)
+			}
+		}
+		for _, ph := range l.Phonemes {
+			alphabet := ph.Alphabet
+			if alphabet == "" {
+				alphabet = doc.Alphabet
+			}
+			lexeme.Phonemes = append(lexeme.Phonemes, LexemePhoneme{Alphabet: alphabet, Ph: strings.TrimSpace(ph.Ph)})
+		}
+		for _, alias := range l.Aliases {
+			lexeme.Aliases = append(lexeme.Aliases, strings.TrimSpace(alias))
+		}
+		if len(lexeme.Graphemes) > 0 {
+			lexicon.Lexemes = append(lexicon.Lexemes, lexeme)
+		}
+	}
+
+	return lexicon, nil
+}
+
+// Lookup 查找与 grapheme 匹配的词条
+func (l *PronunciationLexicon) Lookup(grapheme string) (*Lexeme, bool) {
+	for i := range l.Lexemes {
+		for _, g := range l.Lexemes[i].Graphemes {
+			if g == grapheme {
+				return &l.Lexemes[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// LexiconLoader 根据 URI 加载词典内容
+type LexiconLoader interface {
+	Load(uri string) (io.ReadCloser, error)
+}
+
+// ContextLexiconLoader 支持取消的 LexiconLoader，LexiconRegistry 优先调用 LoadContext
+type ContextLexiconLoader interface {
+	LexiconLoader
+	LoadContext(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// LexiconLoaderFunc 函数形式的 LexiconLoader
+type LexiconLoaderFunc func(uri string) (io.ReadCloser, error)
+
+// Load 调用函数本身
+func (f LexiconLoaderFunc) Load(uri string) (io.ReadCloser, error) { return f(uri) }
+
+// FileLexiconLoader 从本地文件加载词典，路径基于 BaseDir（为空时为当前目录）。
+// URI 来自文档内容，绝对路径和清理后位于 BaseDir 之外的路径（如 ../secret）被拒绝
+type FileLexiconLoader struct {
+	BaseDir string
+}
+
+// Load 打开 BaseDir 中的词典文件
+func (f *FileLexiconLoader) Load(uri string) (io.ReadCloser, error) {
+	path := filepath.FromSlash(strings.TrimPrefix(uri, "file://"))
+	if strings.HasPrefix(path, string(filepath.Separator)) || filepath.IsAbs(path) || filepath.VolumeName(path) != "" {
+		return nil, fmt.Errorf("lexicon %q: absolute paths are not allowed", uri)
+	}
+	if !filepath.IsLocal(path) || filepath.Clean(path) == "." {
+		return nil, fmt.Errorf("lexicon %q: path is outside the base directory", uri)
+	}
+	return os.Open(filepath.Join(f.BaseDir, path))
+}
+
+// MemoryLexiconLoader 从内存中的 URI -> 内容映射加载词典
+type MemoryLexiconLoader map[string]string
+
+// Load 返回 URI 对应的词典内容
+func (m MemoryLexiconLoader) Load(uri string) (io.ReadCloser, error) {
+	content, ok := m[uri]
+	if !ok {
+		return nil, fmt.Errorf("lexicon not found: %s", uri)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+// LexiconRegistry 词典注册表，按 URI 解析并缓存词典。
+// 加载时不持有锁：不同 URI 的词典并行加载，同一 URI 只加载一次，其他调用等待其结果；
+// Load 中可以再调用注册表解析其他 URI。加载失败的结果不缓存，之后的调用会重新加载
+type LexiconRegistry struct {
+	loader LexiconLoader
+	mu     sync.Mutex
+	cache  map[string]*lexiconEntry
+}
+
+// lexiconEntry 一个 URI 的加载结果，done 关闭后 lexicon 和 err 不再改变
+type lexiconEntry struct {
+	done    chan struct{}
+	lexicon *PronunciationLexicon
+	err     error
+}
+
+// NewLexiconRegistry 创建词典注册表
+func NewLexiconRegistry(loader LexiconLoader) *LexiconRegistry {
+	return &LexiconRegistry{
+		loader: loader,
+		cache:  make(map[string]*lexiconEntry),
+	}
+}
+
+// Register 直接注册一个已解析的词典
+func (r *LexiconRegistry) Register(uri string, lexicon *PronunciationLexicon) {
+	entry := &lexiconEntry{done: make(chan struct{}), lexicon: lexicon}
+	close(entry.done)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache[uri] = entry
+}
+
+// Resolve 解析 URI 对应的词典，结果会被缓存
+func (r *LexiconRegistry) Resolve(uri string) (*PronunciationLexicon, error) {
+	return r.ResolveContext(context.Background(), uri)
+}
+
+// ResolveContext 解析 URI 对应的词典，ctx 取消时停止等待；加载器实现 ContextLexiconLoader 时加载同样使用 ctx。
+// 其他调用方的 ctx 取消使加载失败时，本次调用重新加载
+func (r *LexiconRegistry) ResolveContext(ctx context.Context, uri string) (*PronunciationLexicon, error) {
+	for {
+		r.mu.Lock()
+		entry, ok := r.cache[uri]
+		if !ok {
+			if r.loader == nil {
+				r.mu.Unlock()
+				return nil, fmt.Errorf("lexicon %s: no loader configured", uri)
+			}
+			entry = &lexiconEntry{done: make(chan struct{})}
+			r.cache[uri] = entry
+			r.mu.Unlock()
+			return r.load(ctx, uri, entry)
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		cancelled := errors.Is(entry.err, context.Canceled) || errors.Is(entry.err, context.DeadlineExceeded)
+		if cancelled && ctx.Err() == nil {
+			continue
+		}
+		return entry.lexicon, entry.err
+	}
+}
+
+// load 加载词典并记录到 entry 中，失败时从缓存中移除
+func (r *LexiconRegistry) load(ctx context.Context, uri string, entry *lexiconEntry) (*PronunciationLexicon, error) {
+	defer close(entry.done)
+
+	entry.lexicon, entry.err = r.loadLexicon(ctx, uri)
+	if entry.err != nil {
+		r.mu.Lock()
+		if r.cache[uri] == entry {
+			delete(r.cache, uri)
+		}
+		r.mu.Unlock()
+	}
+	return entry.lexicon, entry.err
+}
+
+// loadLexicon 用加载器读取并解析词典
+func (r *LexiconRegistry) loadLexicon(ctx context.Context, uri string) (*PronunciationLexicon, error) {
+	var reader io.ReadCloser
+	var err error
+	if loader, ok := r.loader.(ContextLexiconLoader); ok {
+		reader, err = loader.LoadContext(ctx, uri)
+	} else {
+		reader, err = r.loader.Load(uri)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lexicon %s: %w", uri, err)
+	}
+	defer reader.Close()
+
+	lexicon, err := ParsePLS(reader)
+	if err != nil {
+		return nil, fmt.Errorf("lexicon %s: %w", uri, err)
+	}
+	return lexicon, nil
+}
+
+// lexiconMatch 文本中匹配到的词条
+type lexiconMatch struct {
+	start, end int
+	lexeme     *Lexeme
+}
+
+// findLexeme 在 text 的 pos 处查找最长匹配的词条，lexicons 按优先级排列
+func findLexeme(text string, pos int, lexicons []*PronunciationLexicon) (lexiconMatch, bool) {
+	best := lexiconMatch{}
+	found := false
+
+	for _, lexicon := range lexicons {
+		for i := range lexicon.Lexemes {
+			for _, g := range lexicon.Lexemes[i].Graphemes {
+				end := pos + len(g)
+				if !strings.HasPrefix(text[pos:], g) || (found && end <= best.end) {
+					continue
+				}
+				if !isWordBoundary(text, pos, end) {
+					continue
+				}
+				best = lexiconMatch{start: pos, end: end, lexeme: &lexicon.Lexemes[i]}
+				found = true
+			}
+		}
+		if found {
+			// 内层 lookup 的词典优先
+			return best, true
+		}
+	}
+
+	return best, found
+}
+
+// isWordBoundary 判断 text[start:end] 两侧是否为词边界（只对字母数字生效，CJK 文本不受影响）
+func isWordBoundary(text string, start, end int) bool {
+	if start > 0 {
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		first, _ := utf8.DecodeRuneInString(text[start:])
+		if isLatinWordRune(before) && isLatinWordRune(first) {
+			return false
+		}
+	}
+	if end < len(text) {
+		last, _ := utf8.DecodeLastRuneInString(text[:end])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if isLatinWordRune(last) && isLatinWordRune(after) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLatinWordRune 判断字符是否属于以空格分词的文字
+func isLatinWordRune(r rune) bool {
+	return r < unicode.MaxLatin1 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
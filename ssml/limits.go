@@ -0,0 +1,189 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 超出资源限制时的错误，可用 errors.Is 判断
+var (
+	ErrInputTooLarge     = errors.New("input too large")
+	ErrTooManyElements   = errors.New("too many elements")
+	ErrTextTooLong       = errors.New("text too long")
+	ErrTooManyAttributes = errors.New("too many attributes")
+	ErrBreakTooLong      = errors.New("break too long")
+	ErrNestingTooDeep    = errors.New("nesting too deep")
+	ErrDurationTooLong   = errors.New("duration too long")
+)
+
+// LimitError 超出 ValidationConfig 中资源限制的错误
+type LimitError struct {
+	Code    DiagnosticCode
+	Err     error // 对应的哨兵错误，如 ErrTooManyElements
+	Pos     Position
+	Message string
+}
+
+// Error 返回带位置的错误信息
+func (e *LimitError) Error() string {
+	return formatAt(e.Pos, e.Message)
+}
+
+// Unwrap 返回对应的哨兵错误
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// parseLimits 解析过程中的计数，同一次解析中的解码器共享
+type parseLimits struct {
+	elements int
+	depth    int
+	perNode  bool // 只检查单个节点的限制，不检查元素总数，用于流式解析
+}
+
+// limitedReader 读取超过 max 字节时返回 ErrInputTooLarge
+type limitedReader struct {
+	reader io.Reader
+	read   int64
+	max    int64
+}
+
+// Read 读取数据并累计字节数
+func (r *limitedReader) Read(buf []byte) (int, error) {
+	if remaining := r.max - r.read + 1; int64(len(buf)) > remaining {
+		buf = buf[:remaining]
+	}
+	n, err := r.reader.Read(buf)
+	r.read += int64(n)
+	if r.read > r.max {
+		return n, &LimitError{
+			Code:    CodeMaxInputBytes,
+			Err:     ErrInputTooLarge,
+			Message: fmt.Sprintf("input exceeds %d bytes", r.max),
+		}
+	}
+	return n, err
+}
+
+// limitReader 按 MaxInputBytes 限制输入大小
+func (p *Parser) limitReader(reader io.Reader) io.Reader {
+	if p.config.MaxInputBytes <= 0 {
+		return reader
+	}
+	return &limitedReader{reader: reader, max: p.config.MaxInputBytes}
+}
+
+// checkElement 检查元素数量和属性数量
+func (p *Parser) checkElement(decoder *tokenDecoder, start xml.StartElement, pos Position) error {
+	decoder.limits.elements++
+	if max := p.config.MaxElements; max > 0 && !decoder.limits.perNode && decoder.limits.elements > max {
+		return &LimitError{
+			Code:    CodeMaxElements,
+			Err:     ErrTooManyElements,
+			Pos:     pos,
+			Message: fmt.Sprintf("document has more than %d elements", max),
+		}
+	}
+
+	if max := p.config.MaxAttributes; max > 0 && len(start.Attr) > max {
+		return &LimitError{
+			Code:    CodeMaxAttributes,
+			Err:     ErrTooManyAttributes,
+			Pos:     pos,
+			Message: fmt.Sprintf("element <%s> has %d attributes, limit is %d", start.Name.Local, len(start.Attr), max),
+		}
+	}
+
+	return nil
+}
+
+// checkDepth 检查解析过程中的嵌套深度，避免深层输入耗尽递归栈
+// MaxNestingDepth 为 0 时解析后的验证会拒绝所有元素，这里至少允许一层，让其他错误先被报告
+func (p *Parser) checkDepth(decoder *tokenDecoder, pos Position) error {
+	max := p.config.MaxNestingDepth
+	if max < 1 {
+		max = 1
+	}
+	if decoder.limits.depth > max {
+		return &LimitError{
+			Code:    CodeMaxNestingDepth,
+			Err:     ErrNestingTooDeep,
+			Pos:     pos,
+			Message: fmt.Sprintf("maximum nesting depth exceeded: %d", p.config.MaxNestingDepth),
+		}
+	}
+	return nil
+}
+
+// checkText 检查单个文本节点的长度
+func (p *Parser) checkText(raw xml.CharData, pos Position) error {
+	if max := p.config.MaxTextLength; max > 0 && len(raw) > max {
+		return &LimitError{
+			Code:    CodeMaxTextLength,
+			Err:     ErrTextTooLong,
+			Pos:     pos,
+			Message: fmt.Sprintf("text node has %d bytes, limit is %d", len(raw), max),
+		}
+	}
+	return nil
+}
+
+// checkBreak 检查 break 的时长；DurationCheckWarn 时由时长检查报告警告
+func (p *Parser) checkBreak(breakElem *Break) error {
+	max := p.config.MaxBreakDuration
+	if max <= 0 || breakElem.Time == "" || p.config.DurationCheck == DurationCheckWarn {
+		return nil
+	}
+
+	duration, err := parseTimeValue(breakElem.Time)
+	if err != nil && !errors.Is(err, errTimeOutOfRange) {
+		// 格式错误由属性验证报告
+		return nil
+	}
+	if err != nil || duration > max {
+		return &LimitError{
+			Code:    CodeMaxBreakDuration,
+			Err:     ErrBreakTooLong,
+			Pos:     breakElem.Start,
+			Message: fmt.Sprintf("break time %s exceeds %s", breakElem.Time, max),
+		}
+	}
+	return nil
+}
+
+// errTimeOutOfRange 时间值超出 time.Duration 的范围
+var errTimeOutOfRange = errors.New("time value out of range")
+
+// parseTimeValue 解析 SSML 时间值（如 "1s"、"500ms"、"1.5s"），也接受 time.ParseDuration 的格式
+func parseTimeValue(timeStr string) (time.Duration, error) {
+	timeStr = strings.TrimSpace(timeStr)
+
+	var value float64
+	var unit time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(timeStr, "ms"):
+		value, err = strconv.ParseFloat(strings.TrimSuffix(timeStr, "ms"), 64)
+		unit = time.Millisecond
+	case strings.HasSuffix(timeStr, "s"):
+		value, err = strconv.ParseFloat(strings.TrimSuffix(timeStr, "s"), 64)
+		unit = time.Second
+	default:
+		return time.ParseDuration(timeStr)
+	}
+	if err != nil {
+		return time.ParseDuration(timeStr)
+	}
+
+	nanos := value * float64(unit)
+	if math.IsNaN(nanos) || math.Abs(nanos) >= math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %s", errTimeOutOfRange, timeStr)
+	}
+	return time.Duration(nanos), nil
+}
@@ -1,16 +1,23 @@
 package ssml
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
+	"html"
 	"io"
-	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Parser SSML 解析器
 type Parser struct {
-	config *ValidationConfig
+	config    *ValidationConfig
+	registry  *ElementRegistry
+	estimator *AudioProcessor // 时长检查使用的音频处理器，为 nil 时使用 NewAudioProcessor()
 }
 
 // NewParser 创建新的解析器
@@ -18,22 +25,159 @@ func NewParser(config *ValidationConfig) *Parser {
 	if config == nil {
 		config = DefaultValidationConfig()
 	}
-	return &Parser{config: config}
+	return &Parser{config: config, registry: DefaultElementRegistry}
+}
+
+// SetElementRegistry 设置自定义元素注册表
+func (p *Parser) SetElementRegistry(registry *ElementRegistry) {
+	p.registry = registry
+}
+
+// SetAudioProcessor 设置时长检查使用的音频处理器，使估算与实际处理使用相同的配置
+func (p *Parser) SetAudioProcessor(processor *AudioProcessor) {
+	p.estimator = processor
+}
+
+// tokenDecoder 包装 xml.Decoder，记录最近一个 token 的起始位置
+type tokenDecoder struct {
+	*xml.Decoder
+	tokenStart    Position
+	coreNamespace string      // speak 元素所在的命名空间，其中的元素按 SSML 核心元素解析
+	namespaces    []xml.Attr  // speak 上的 xmlns 声明，用于还原 metadata 内部的前缀
+	preserveSpace bool        // 当前是否处于 xml:space="preserve" 作用域
+	pending       []xml.Token // 优先于输入返回的 token
+	limits        *parseLimits
+	ctx           context.Context // 每读取一个 token 前检查是否已取消
+	dropped       []Diagnostic    // 从空元素中丢弃的内容，解析结束后报告
+	source        *sourceMap      // 恢复模式下修复后的文本到原文的映射，位置按原文计算
+}
+
+// newTokenDecoder 创建记录位置的解码器
+// 输入按 BOM 或 XML 声明中的编码转换为 UTF-8，位置基于转换后的文本
+func newTokenDecoder(ctx context.Context, reader io.Reader) *tokenDecoder {
+	reader, converted := sniffEncoding(reader)
+	decoder := xml.NewDecoder(reader)
+	decoder.CharsetReader = charsetReaderFor(converted)
+	return &tokenDecoder{Decoder: decoder, limits: &parseLimits{}, ctx: ctx}
+}
+
+// Token 读取下一个 token，并记录其起始位置；上下文已取消时返回 ctx.Err()
+func (d *tokenDecoder) Token() (xml.Token, error) {
+	d.tokenStart = d.position()
+	if err := d.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(d.pending) > 0 {
+		token := d.pending[0]
+		d.pending = d.pending[1:]
+		return token, nil
+	}
+	return d.Decoder.Token()
+}
+
+// Skip 跳过当前元素剩余的 token，直到与之匹配的结束标签
+func (d *tokenDecoder) Skip() error {
+	depth := 0
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// isCore 判断元素是否按 SSML 核心元素解析
+func (d *tokenDecoder) isCore(name xml.Name) bool {
+	return name.Space == d.coreNamespace || canonicalNamespace(name.Space) == SSMLNamespace
+}
+
+// position 返回解码器当前所在的位置
+func (d *tokenDecoder) position() Position {
+	if d.source != nil {
+		return d.source.position(d.InputOffset())
+	}
+	line, column := d.InputPos()
+	return Position{Line: line, Column: column, Offset: d.InputOffset()}
+}
+
+// contextReader 每次读取前检查上下文是否已取消，用于一次读完整个输入的恢复模式
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read 上下文未取消时读取数据，已取消时返回 ctx.Err()
+func (r *contextReader) Read(buf []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(buf)
+}
+
+// advancePosition 返回 pos 越过字符串 s 之后的位置
+func advancePosition(pos Position, s string) Position {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	pos.Offset += int64(len(s))
+	return pos
+}
+
+// advanceBytes 返回 pos 越过字节 b 之后的位置
+func advanceBytes(pos Position, b []byte) Position {
+	for _, c := range b {
+		if c == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	pos.Offset += int64(len(b))
+	return pos
 }
 
 // Parse 解析 SSML 字符串
 func (p *Parser) Parse(ssmlContent string) (*ParseResult, error) {
-	return p.ParseReader(strings.NewReader(ssmlContent))
+	return p.ParseReaderContext(context.Background(), strings.NewReader(ssmlContent))
+}
+
+// ParseContext 解析 SSML 字符串，ctx 取消时停止解析
+func (p *Parser) ParseContext(ctx context.Context, ssmlContent string) (*ParseResult, error) {
+	return p.ParseReaderContext(ctx, strings.NewReader(ssmlContent))
 }
 
 // ParseReader 从 Reader 解析 SSML
 func (p *Parser) ParseReader(reader io.Reader) (*ParseResult, error) {
+	return p.ParseReaderContext(context.Background(), reader)
+}
+
+// ParseReaderContext 从 Reader 解析 SSML，每读取一个 token 前检查 ctx，
+// 取消时直接返回 ctx.Err()，不记录诊断信息
+func (p *Parser) ParseReaderContext(ctx context.Context, reader io.Reader) (*ParseResult, error) {
 	result := &ParseResult{
-		Warnings: []string{},
-		Errors:   []string{},
+		Warnings: []Diagnostic{},
+		Errors:   []Diagnostic{},
 	}
 
-	decoder := xml.NewDecoder(reader)
+	decoder, err := p.newDecoder(ctx, reader, result, true)
+	if err != nil {
+		return result, err
+	}
 	var root *Speak
 
 	for {
@@ -42,51 +186,158 @@ func (p *Parser) ParseReader(reader io.Reader) (*ParseResult, error) {
 			break
 		}
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("XML parsing error: %v", err))
-			return result, err
+			return result, p.reportParseError(decoder, err, CodeXMLSyntax, result)
 		}
 
 		switch se := token.(type) {
 		case xml.StartElement:
-			if se.Name.Local == "speak" {
-				speak := &Speak{}
-				if err := p.parseSpeak(decoder, se, speak); err != nil {
-					result.Errors = append(result.Errors, fmt.Sprintf("Error parsing speak element: %v", err))
+			if se.Name.Local == "speak" && root != nil {
+				// 只返回第一个文档，多个文档应使用 DocumentScanner
+				p.report(result, Diagnostic{
+					Code:     CodeMultipleRoots,
+					Severity: SeverityWarning,
+					Message:  "Input contains more than one <speak> document; only the first is returned",
+					Span:     Span{Start: decoder.tokenStart, End: decoder.position()},
+					Fix:      "use Parser.NewDocumentScanner to read concatenated documents",
+				})
+				if err := decoder.Skip(); err != nil {
+					return result, p.reportParseError(decoder, err, CodeXMLSyntax, result)
+				}
+			} else if se.Name.Local == "speak" {
+				speak, err := p.parseDocument(decoder, se, result)
+				if err != nil {
 					return result, err
 				}
 				root = speak
 			} else {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Root element should be 'speak', found '%s'", se.Name.Local))
+				p.report(result, Diagnostic{
+					Code:     CodeInvalidRoot,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("Root element should be 'speak', found '%s'", se.Name.Local),
+					Span:     Span{Start: decoder.tokenStart, End: decoder.position()},
+					Fix:      "wrap the document in a <speak> element",
+				})
 			}
 		}
 	}
 
 	if root == nil {
-		result.Errors = append(result.Errors, "No valid SSML root element found")
+		p.report(result, Diagnostic{
+			Code:     CodeMissingRoot,
+			Severity: SeverityError,
+			Message:  "No valid SSML root element found",
+			Fix:      "wrap the document in a <speak> element",
+		})
 		return result, fmt.Errorf("no valid SSML root element found")
 	}
 
 	result.Root = root
 
+	if err := p.reportDropped(decoder, result); err != nil {
+		return result, err
+	}
+
 	// 验证解析结果
-	if err := p.validate(root, result); err != nil {
+	if err := p.validate(ctx, root, result); err != nil {
 		return result, err
 	}
 
 	return result, nil
 }
 
+// parseDocument 解析 speak 元素，出错时在出错的 token 处记录诊断信息
+func (p *Parser) parseDocument(decoder *tokenDecoder, start xml.StartElement, result *ParseResult) (*Speak, error) {
+	speak := &Speak{Span: Span{Start: decoder.tokenStart}}
+	if err := p.parseSpeak(decoder, start, speak); err != nil {
+		return nil, p.reportParseError(decoder, err, CodeElementParseError, result)
+	}
+	return speak, nil
+}
+
+// newDecoder 创建解码器；恢复模式下先修复输入，wrapRoot 表示是否为缺少根元素的内容补上 speak
+func (p *Parser) newDecoder(ctx context.Context, reader io.Reader, result *ParseResult, wrapRoot bool) (*tokenDecoder, error) {
+	reader = p.limitReader(reader)
+	if !p.config.Recover {
+		return newTokenDecoder(ctx, reader), nil
+	}
+
+	data, err := io.ReadAll(&contextReader{ctx: ctx, reader: reader})
+	if cerr := contextError(err); cerr != nil {
+		return nil, cerr
+	}
+	if err != nil {
+		p.report(result, Diagnostic{
+			Code:     errorCode(err, CodeXMLSyntax),
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("reading input: %v", err),
+		})
+		return nil, err
+	}
+	// 修复前先转换为 UTF-8，避免替换的实体与原编码混在一起
+	data, err = decodeDocument(data)
+	if err != nil {
+		p.report(result, Diagnostic{
+			Code:     CodeXMLSyntax,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("decoding input: %v", err),
+		})
+		return nil, err
+	}
+	if !utf8.Valid(data) {
+		p.report(result, Diagnostic{
+			Code:     CodeInvalidEncoding,
+			Severity: SeverityWarning,
+			Message:  "Input contains invalid UTF-8; replaced with U+FFFD",
+			Fix:      "declare the input encoding in the XML declaration",
+		})
+		data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+	}
+	repaired, source, repairs := repairSSML(string(data), p.recoveryEntities(), wrapRoot)
+	for _, d := range repairs {
+		p.report(result, d)
+	}
+
+	decoder := newTokenDecoder(ctx, strings.NewReader(repaired))
+	decoder.Strict = false
+	decoder.Entity = p.recoveryEntities()
+	decoder.CharsetReader = charsetReaderFor(true)
+	decoder.source = source
+	return decoder, nil
+}
+
 // parseSpeak 解析 speak 元素
-func (p *Parser) parseSpeak(decoder *xml.Decoder, start xml.StartElement, speak *Speak) error {
+func (p *Parser) parseSpeak(decoder *tokenDecoder, start xml.StartElement, speak *Speak) error {
+	if err := p.checkElement(decoder, start, speak.Start); err != nil {
+		return err
+	}
+
 	speak.XMLName = start.Name
+	speak.Whitespace = p.config.Whitespace
+	decoder.coreNamespace = start.Name.Space
+	if space, ok := xmlSpace(start); ok {
+		decoder.preserveSpace = space == "preserve"
+	}
+	if decoder.preserveSpace {
+		speak.Whitespace = WhitespacePreserve
+	}
 
 	// 解析属性
 	for _, attr := range start.Attr {
+		if namespace, ok := namespaceDecl(attr); ok {
+			speak.Namespaces = append(speak.Namespaces, namespace)
+			decoder.namespaces = append(decoder.namespaces, attr)
+			continue
+		}
+
 		switch attr.Name.Local {
 		case "version":
 			speak.Version = attr.Value
 		case "lang":
 			speak.Lang = attr.Value
+		case "onlangfailure":
+			speak.OnLangFailure = attr.Value
+		default:
+			speak.Attrs = append(speak.Attrs, attr)
 		}
 	}
 
@@ -96,12 +347,13 @@ func (p *Parser) parseSpeak(decoder *xml.Decoder, start xml.StartElement, speak
 		return err
 	}
 	speak.Content = content
+	speak.End = decoder.position()
 
 	return nil
 }
 
 // parseContent 解析元素内容
-func (p *Parser) parseContent(decoder *xml.Decoder, parentTag string) ([]interface{}, error) {
+func (p *Parser) parseContent(decoder *tokenDecoder, parentTag string) ([]interface{}, error) {
 	var content []interface{}
 
 	for {
@@ -112,7 +364,7 @@ func (p *Parser) parseContent(decoder *xml.Decoder, parentTag string) ([]interfa
 
 		switch se := token.(type) {
 		case xml.StartElement:
-			element, err := p.parseElement(decoder, se)
+			element, err := p.parseElement(decoder, se, decoder.tokenStart)
 			if err != nil {
 				return nil, err
 			}
@@ -121,9 +373,11 @@ func (p *Parser) parseContent(decoder *xml.Decoder, parentTag string) ([]interfa
 			}
 
 		case xml.CharData:
-			text := strings.TrimSpace(string(se))
-			if text != "" {
-				content = append(content, Text{Content: text})
+			if err := p.checkText(se, decoder.tokenStart); err != nil {
+				return nil, err
+			}
+			if text, ok := p.textNode(decoder, se); ok {
+				content = append(content, text)
 			}
 
 		case xml.EndElement:
@@ -134,45 +388,219 @@ func (p *Parser) parseContent(decoder *xml.Decoder, parentTag string) ([]interfa
 	}
 }
 
-// parseElement 解析单个元素
-func (p *Parser) parseElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+// textNode 按空白处理模式生成文本节点，文本为空时返回 false；
+// 默认模式下先在字节上去掉首尾空白，元素之间的纯空白不分配字符串
+func (p *Parser) textNode(decoder *tokenDecoder, raw []byte) (Text, bool) {
+	mode := p.config.Whitespace
+	if decoder.preserveSpace {
+		mode = WhitespacePreserve
+	}
+
+	switch mode {
+	case WhitespacePreserve:
+		if len(raw) == 0 {
+			return Text{}, false
+		}
+		text := string(raw)
+		return Text{Content: text, Span: Span{Start: decoder.tokenStart, End: advancePosition(decoder.tokenStart, text)}}, true
+	case WhitespaceNormalize:
+		if len(raw) == 0 {
+			return Text{}, false
+		}
+		text := string(raw)
+		return Text{Content: collapseSpace(text), Span: Span{Start: decoder.tokenStart, End: advancePosition(decoder.tokenStart, text)}}, true
+	default:
+		trimmed := bytes.TrimSpace(raw)
+		if len(trimmed) == 0 {
+			return Text{}, false
+		}
+		leading := len(raw) - len(bytes.TrimLeftFunc(raw, unicode.IsSpace))
+		start := advanceBytes(decoder.tokenStart, raw[:leading])
+		text := string(trimmed)
+		return Text{Content: text, Span: Span{Start: start, End: advancePosition(start, text)}}, true
+	}
+}
+
+// collapseSpace 将连续空白合并为一个空格，保留首尾的空白
+func collapseSpace(s string) string {
+	var builder strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			builder.WriteByte(' ')
+			space = false
+		}
+		builder.WriteRune(r)
+	}
+	if space {
+		builder.WriteByte(' ')
+	}
+	return builder.String()
+}
+
+// xmlSpace 返回元素的 xml:space 属性值
+func xmlSpace(start xml.StartElement) (string, bool) {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == xmlNamespace && attr.Name.Local == "space" {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// parseElement 根据命名空间解析单个元素
+func (p *Parser) parseElement(decoder *tokenDecoder, start xml.StartElement, pos Position) (interface{}, error) {
+	if err := p.checkElement(decoder, start, pos); err != nil {
+		return nil, err
+	}
+
+	// 解析时即检查嵌套深度，避免深层输入耗尽递归栈
+	decoder.limits.depth++
+	defer func() { decoder.limits.depth-- }()
+	if err := p.checkDepth(decoder, pos); err != nil {
+		return nil, err
+	}
+
+	// xml:space 作用于元素及其子孙节点
+	if space, ok := xmlSpace(start); ok {
+		saved := decoder.preserveSpace
+		decoder.preserveSpace = space == "preserve"
+		defer func() { decoder.preserveSpace = saved }()
+	}
+
+	// 注册的自定义元素优先
+	if handler, ok := p.registry.Lookup(start.Name); ok && handler.Parse != nil {
+		return p.parseCustom(decoder, start, pos, handler)
+	}
+
+	if decoder.isCore(start.Name) {
+		return p.parseCoreElement(decoder, start, pos)
+	}
+
+	switch canonicalNamespace(start.Name.Space) {
+	case MSTTSNamespace:
+		switch start.Name.Local {
+		case "express-as":
+			return p.parseMSTTSExpressAs(decoder, start, pos)
+		case "silence":
+			return p.parseMSTTSSilence(decoder, start, pos)
+		}
+	case AmazonNamespace:
+		switch start.Name.Local {
+		case "effect":
+			return p.parseAmazonEffect(decoder, start, pos)
+		case "domain":
+			return p.parseAmazonDomain(decoder, start, pos)
+		}
+	}
+
+	return p.parseUnknownElement(decoder, start, pos)
+}
+
+// parseCoreElement 解析 SSML 核心元素
+func (p *Parser) parseCoreElement(decoder *tokenDecoder, start xml.StartElement, pos Position) (interface{}, error) {
 	switch start.Name.Local {
 	case "audio":
-		return p.parseAudio(decoder, start)
+		return p.parseAudio(decoder, start, pos)
 	case "break":
-		return p.parseBreak(decoder, start)
+		return p.parseBreak(decoder, start, pos)
 	case "emphasis":
-		return p.parseEmphasis(decoder, start)
+		return p.parseEmphasis(decoder, start, pos)
+	case "lexicon":
+		return p.parseLexicon(decoder, start, pos)
+	case "lookup":
+		return p.parseLookup(decoder, start, pos)
+	case "mark":
+		return p.parseMark(decoder, start, pos)
+	case "meta":
+		return p.parseMeta(decoder, start, pos)
+	case "metadata":
+		return p.parseMetadata(decoder, start, pos)
+	case "desc":
+		return p.parseDesc(decoder, start, pos)
 	case "p":
-		return p.parseParagraph(decoder, start)
+		return p.parseParagraph(decoder, start, pos)
 	case "phoneme":
-		return p.parsePhoneme(decoder, start)
+		return p.parsePhoneme(decoder, start, pos)
 	case "prosody":
-		return p.parseProsody(decoder, start)
+		return p.parseProsody(decoder, start, pos)
 	case "s":
-		return p.parseSentence(decoder, start)
+		return p.parseSentence(decoder, start, pos)
+	case "say-as":
+		return p.parseSayAs(decoder, start, pos)
 	case "sub":
-		return p.parseSub(decoder, start)
+		return p.parseSub(decoder, start, pos)
 	case "voice":
-		return p.parseVoice(decoder, start)
-	case "w":
-		return p.parseW(decoder, start)
+		return p.parseVoice(decoder, start, pos)
+	case "w", "token":
+		return p.parseW(decoder, start, pos)
+	case "lang":
+		return p.parseLang(decoder, start, pos)
 	default:
-		if !p.config.AllowUnknownElements {
-			return nil, fmt.Errorf("unknown element: %s", start.Name.Local)
-		}
-		// 跳过未知元素
-		return p.skipElement(decoder, start.Name.Local)
+		return p.parseUnknownElement(decoder, start, pos)
+	}
+}
+
+// parseUnknownElement 处理无法识别的元素
+func (p *Parser) parseUnknownElement(decoder *tokenDecoder, start xml.StartElement, pos Position) (interface{}, error) {
+	if !p.config.AllowUnknownElements {
+		return nil, fmt.Errorf("%s: unknown element: %s", pos, qualifiedName(start.Name))
+	}
+	// 保留未知元素
+	return p.parseUnknown(decoder, start, pos)
+}
+
+// canonicalNamespace 将命名空间的常见写法归一化
+func canonicalNamespace(space string) string {
+	switch space {
+	case "", SSMLNamespace:
+		return SSMLNamespace
+	case MSTTSNamespace, "http://www.w3.org/2001/mstts", "mstts":
+		return MSTTSNamespace
 	}
+	return space
+}
+
+// qualifiedName 返回带常用前缀的元素名，用于诊断信息
+func qualifiedName(name xml.Name) string {
+	switch canonicalNamespace(name.Space) {
+	case SSMLNamespace:
+		return name.Local
+	case MSTTSNamespace:
+		return "mstts:" + name.Local
+	case AmazonNamespace:
+		return "amazon:" + name.Local
+	case "google": // Google 的扩展同样不声明命名空间
+		return "google:" + name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+// namespaceDecl 判断属性是否为 xmlns 声明
+func namespaceDecl(attr xml.Attr) (Namespace, bool) {
+	switch {
+	case attr.Name.Space == "xmlns":
+		return Namespace{Prefix: attr.Name.Local, URI: attr.Value}, true
+	case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+		return Namespace{URI: attr.Value}, true
+	}
+	return Namespace{}, false
 }
 
 // parseAudio 解析 audio 元素
-func (p *Parser) parseAudio(decoder *xml.Decoder, start xml.StartElement) (*Audio, error) {
-	audio := &Audio{XMLName: start.Name}
+func (p *Parser) parseAudio(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Audio, error) {
+	audio := &Audio{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
-		if attr.Name.Local == "src" {
+		switch attr.Name.Local {
+		case "src":
 			audio.Src = attr.Value
+		default:
+			audio.Attrs = append(audio.Attrs, attr)
 		}
 	}
 
@@ -181,13 +609,14 @@ func (p *Parser) parseAudio(decoder *xml.Decoder, start xml.StartElement) (*Audi
 		return nil, err
 	}
 	audio.Content = content
+	audio.End = decoder.position()
 
 	return audio, nil
 }
 
 // parseBreak 解析 break 元素
-func (p *Parser) parseBreak(decoder *xml.Decoder, start xml.StartElement) (*Break, error) {
-	breakElem := &Break{XMLName: start.Name}
+func (p *Parser) parseBreak(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Break, error) {
+	breakElem := &Break{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
@@ -195,30 +624,34 @@ func (p *Parser) parseBreak(decoder *xml.Decoder, start xml.StartElement) (*Brea
 			breakElem.Time = attr.Value
 		case "strength":
 			breakElem.Strength = attr.Value
+		default:
+			breakElem.Attrs = append(breakElem.Attrs, attr)
 		}
 	}
 
-	// break 是自闭合元素，跳过到结束标签
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			return nil, err
-		}
-		if endElement, ok := token.(xml.EndElement); ok && endElement.Name.Local == "break" {
-			break
-		}
+	if err := p.checkBreak(breakElem); err != nil {
+		return nil, err
+	}
+
+	// break 是空元素，跳过到结束标签，其中的内容被丢弃
+	if err := p.skipEmptyElement(decoder, start, pos); err != nil {
+		return nil, err
 	}
+	breakElem.End = decoder.position()
 
 	return breakElem, nil
 }
 
 // parseEmphasis 解析 emphasis 元素
-func (p *Parser) parseEmphasis(decoder *xml.Decoder, start xml.StartElement) (*Emphasis, error) {
-	emphasis := &Emphasis{XMLName: start.Name}
+func (p *Parser) parseEmphasis(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Emphasis, error) {
+	emphasis := &Emphasis{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
-		if attr.Name.Local == "level" {
+		switch attr.Name.Local {
+		case "level":
 			emphasis.Level = attr.Value
+		default:
+			emphasis.Attrs = append(emphasis.Attrs, attr)
 		}
 	}
 
@@ -227,26 +660,231 @@ func (p *Parser) parseEmphasis(decoder *xml.Decoder, start xml.StartElement) (*E
 		return nil, err
 	}
 	emphasis.Content = content
+	emphasis.End = decoder.position()
 
 	return emphasis, nil
 }
 
+// parseLexicon 解析 lexicon 元素
+func (p *Parser) parseLexicon(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Lexicon, error) {
+	lexicon := &Lexicon{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "uri":
+			lexicon.URI = attr.Value
+		case "id":
+			lexicon.ID = attr.Value
+		case "type":
+			lexicon.Type = attr.Value
+		default:
+			lexicon.Attrs = append(lexicon.Attrs, attr)
+		}
+	}
+
+	// lexicon 是空元素，跳过到结束标签，其中的内容被丢弃
+	if err := p.skipEmptyElement(decoder, start, pos); err != nil {
+		return nil, err
+	}
+	lexicon.End = decoder.position()
+
+	return lexicon, nil
+}
+
+// parseLookup 解析 lookup 元素
+func (p *Parser) parseLookup(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Lookup, error) {
+	lookup := &Lookup{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "ref":
+			lookup.Ref = attr.Value
+		default:
+			lookup.Attrs = append(lookup.Attrs, attr)
+		}
+	}
+
+	content, err := p.parseContent(decoder, "lookup")
+	if err != nil {
+		return nil, err
+	}
+	lookup.Content = content
+	lookup.End = decoder.position()
+
+	return lookup, nil
+}
+
+// parseMark 解析 mark 元素
+func (p *Parser) parseMark(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Mark, error) {
+	mark := &Mark{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "name":
+			mark.Name = attr.Value
+		default:
+			mark.Attrs = append(mark.Attrs, attr)
+		}
+	}
+
+	// mark 是空元素，跳过到结束标签，其中的内容被丢弃
+	if err := p.skipEmptyElement(decoder, start, pos); err != nil {
+		return nil, err
+	}
+	mark.End = decoder.position()
+
+	return mark, nil
+}
+
+// parseMeta 解析 meta 元素
+func (p *Parser) parseMeta(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Meta, error) {
+	meta := &Meta{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "name":
+			meta.Name = attr.Value
+		case "http-equiv":
+			meta.HTTPEquiv = attr.Value
+		case "content":
+			meta.Value = attr.Value
+		default:
+			meta.Attrs = append(meta.Attrs, attr)
+		}
+	}
+
+	// meta 是空元素，跳过到结束标签，其中的内容被丢弃
+	if err := p.skipEmptyElement(decoder, start, pos); err != nil {
+		return nil, err
+	}
+	meta.End = decoder.position()
+
+	return meta, nil
+}
+
+// parseMetadata 解析 metadata 元素，保留内部 XML
+func (p *Parser) parseMetadata(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Metadata, error) {
+	metadata := &Metadata{XMLName: start.Name, Span: Span{Start: pos}}
+	metadata.Attrs = append(metadata.Attrs, start.Attr...)
+
+	inner, err := p.readInnerXML(decoder, start)
+	if err != nil {
+		return nil, err
+	}
+	metadata.InnerXML = inner
+	metadata.End = decoder.position()
+
+	return metadata, nil
+}
+
+// readInnerXML 读取元素剩余的内容直到结束标签，重建其内部 XML。
+// 内容逐个经由 Token 读取，取消和元素数量、属性数量、文本长度的限制照常检查；
+// 前缀按 speak 和内部的 xmlns 声明还原，空元素保留 <a/> 的写法，特殊字符按序列化器的方式转义
+func (p *Parser) readInnerXML(decoder *tokenDecoder, start xml.StartElement) (string, error) {
+	writer := &ssmlWriter{}
+	writer.declare(0, decoder.namespaces)
+	writer.declare(1, start.Attr)
+
+	var names []string // 未结束的元素的限定名
+	open := int64(-1)  // 开始标签的 > 尚未写出时为标签结束处的偏移
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if open >= 0 {
+			// <a/> 的结束标签不占用输入
+			if _, ok := token.(xml.EndElement); ok && decoder.InputOffset() == open {
+				writer.WriteString("/>")
+				open = -1
+				names = names[:len(names)-1]
+				continue
+			}
+			writer.WriteByte('>')
+			open = -1
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := p.checkElement(decoder, t, decoder.tokenStart); err != nil {
+				return "", err
+			}
+			depth := len(names) + 2
+			writer.declare(depth, t.Attr)
+			name, decl := writer.qualify(depth, t.Name, false)
+			writer.WriteString("<" + name + decl)
+			for _, attr := range t.Attr {
+				attrName, attrDecl := writer.qualify(depth, attr.Name, true)
+				writer.WriteString(attrDecl + " " + attrName + `="` + html.EscapeString(attr.Value) + `"`)
+			}
+			names = append(names, name)
+			open = decoder.InputOffset()
+		case xml.EndElement:
+			if len(names) == 0 {
+				return writer.String(), nil
+			}
+			writer.WriteString("</" + names[len(names)-1] + ">")
+			names = names[:len(names)-1]
+		case xml.CharData:
+			if err := p.checkText(t, decoder.tokenStart); err != nil {
+				return "", err
+			}
+			writer.WriteString(html.EscapeString(string(t)))
+		case xml.Comment:
+			writer.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			writer.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				writer.WriteString(" " + string(t.Inst))
+			}
+			writer.WriteString("?>")
+		case xml.Directive:
+			writer.WriteString("<!" + string(t) + ">")
+		}
+	}
+}
+
+// parseDesc 解析 desc 元素
+func (p *Parser) parseDesc(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Desc, error) {
+	desc := &Desc{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "lang":
+			desc.Lang = attr.Value
+		default:
+			desc.Attrs = append(desc.Attrs, attr)
+		}
+	}
+
+	content, err := p.parseContent(decoder, "desc")
+	if err != nil {
+		return nil, err
+	}
+	desc.Content = content
+	desc.End = decoder.position()
+
+	return desc, nil
+}
+
 // parseParagraph 解析 p 元素
-func (p *Parser) parseParagraph(decoder *xml.Decoder, start xml.StartElement) (*Paragraph, error) {
-	paragraph := &Paragraph{XMLName: start.Name}
+func (p *Parser) parseParagraph(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Paragraph, error) {
+	paragraph := &Paragraph{XMLName: start.Name, Span: Span{Start: pos}}
+	paragraph.Attrs = append(paragraph.Attrs, start.Attr...)
 
 	content, err := p.parseContent(decoder, "p")
 	if err != nil {
 		return nil, err
 	}
 	paragraph.Content = content
+	paragraph.End = decoder.position()
 
 	return paragraph, nil
 }
 
 // parsePhoneme 解析 phoneme 元素
-func (p *Parser) parsePhoneme(decoder *xml.Decoder, start xml.StartElement) (*Phoneme, error) {
-	phoneme := &Phoneme{XMLName: start.Name}
+func (p *Parser) parsePhoneme(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Phoneme, error) {
+	phoneme := &Phoneme{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
@@ -254,6 +892,8 @@ func (p *Parser) parsePhoneme(decoder *xml.Decoder, start xml.StartElement) (*Ph
 			phoneme.Alphabet = attr.Value
 		case "ph":
 			phoneme.Ph = attr.Value
+		default:
+			phoneme.Attrs = append(phoneme.Attrs, attr)
 		}
 	}
 
@@ -262,13 +902,14 @@ func (p *Parser) parsePhoneme(decoder *xml.Decoder, start xml.StartElement) (*Ph
 		return nil, err
 	}
 	phoneme.Content = content
+	phoneme.End = decoder.position()
 
 	return phoneme, nil
 }
 
 // parseProsody 解析 prosody 元素
-func (p *Parser) parseProsody(decoder *xml.Decoder, start xml.StartElement) (*Prosody, error) {
-	prosody := &Prosody{XMLName: start.Name}
+func (p *Parser) parseProsody(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Prosody, error) {
+	prosody := &Prosody{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
@@ -280,6 +921,8 @@ func (p *Parser) parseProsody(decoder *xml.Decoder, start xml.StartElement) (*Pr
 			prosody.Range = attr.Value
 		case "volume":
 			prosody.Volume = attr.Value
+		default:
+			prosody.Attrs = append(prosody.Attrs, attr)
 		}
 	}
 
@@ -288,30 +931,36 @@ func (p *Parser) parseProsody(decoder *xml.Decoder, start xml.StartElement) (*Pr
 		return nil, err
 	}
 	prosody.Content = content
+	prosody.End = decoder.position()
 
 	return prosody, nil
 }
 
 // parseSentence 解析 s 元素
-func (p *Parser) parseSentence(decoder *xml.Decoder, start xml.StartElement) (*Sentence, error) {
-	sentence := &Sentence{XMLName: start.Name}
+func (p *Parser) parseSentence(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Sentence, error) {
+	sentence := &Sentence{XMLName: start.Name, Span: Span{Start: pos}}
+	sentence.Attrs = append(sentence.Attrs, start.Attr...)
 
 	content, err := p.parseContent(decoder, "s")
 	if err != nil {
 		return nil, err
 	}
 	sentence.Content = content
+	sentence.End = decoder.position()
 
 	return sentence, nil
 }
 
 // parseSub 解析 sub 元素
-func (p *Parser) parseSub(decoder *xml.Decoder, start xml.StartElement) (*Sub, error) {
-	sub := &Sub{XMLName: start.Name}
+func (p *Parser) parseSub(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Sub, error) {
+	sub := &Sub{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
-		if attr.Name.Local == "alias" {
+		switch attr.Name.Local {
+		case "alias":
 			sub.Alias = attr.Value
+		default:
+			sub.Attrs = append(sub.Attrs, attr)
 		}
 	}
 
@@ -320,13 +969,41 @@ func (p *Parser) parseSub(decoder *xml.Decoder, start xml.StartElement) (*Sub, e
 		return nil, err
 	}
 	sub.Content = content
+	sub.End = decoder.position()
 
 	return sub, nil
 }
 
+// parseSayAs 解析 say-as 元素
+func (p *Parser) parseSayAs(decoder *tokenDecoder, start xml.StartElement, pos Position) (*SayAs, error) {
+	sayAs := &SayAs{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "interpret-as":
+			sayAs.InterpretAs = attr.Value
+		case "format":
+			sayAs.Format = attr.Value
+		case "detail":
+			sayAs.Detail = attr.Value
+		default:
+			sayAs.Attrs = append(sayAs.Attrs, attr)
+		}
+	}
+
+	content, err := p.parseContent(decoder, "say-as")
+	if err != nil {
+		return nil, err
+	}
+	sayAs.Content = content
+	sayAs.End = decoder.position()
+
+	return sayAs, nil
+}
+
 // parseVoice 解析 voice 元素
-func (p *Parser) parseVoice(decoder *xml.Decoder, start xml.StartElement) (*Voice, error) {
-	voice := &Voice{XMLName: start.Name}
+func (p *Parser) parseVoice(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Voice, error) {
+	voice := &Voice{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
@@ -340,6 +1017,10 @@ func (p *Parser) parseVoice(decoder *xml.Decoder, start xml.StartElement) (*Voic
 			voice.Name = attr.Value
 		case "lang":
 			voice.Languages = attr.Value
+		case "onlangfailure":
+			voice.OnLangFailure = attr.Value
+		default:
+			voice.Attrs = append(voice.Attrs, attr)
 		}
 	}
 
@@ -348,114 +1029,387 @@ func (p *Parser) parseVoice(decoder *xml.Decoder, start xml.StartElement) (*Voic
 		return nil, err
 	}
 	voice.Content = content
+	voice.End = decoder.position()
 
 	return voice, nil
 }
 
-// parseW 解析 w 元素
-func (p *Parser) parseW(decoder *xml.Decoder, start xml.StartElement) (*W, error) {
-	w := &W{XMLName: start.Name}
+// parseLang 解析 lang 元素
+func (p *Parser) parseLang(decoder *tokenDecoder, start xml.StartElement, pos Position) (*Lang, error) {
+	lang := &Lang{XMLName: start.Name, Span: Span{Start: pos}}
 
 	for _, attr := range start.Attr {
-		if attr.Name.Local == "role" {
+		switch attr.Name.Local {
+		case "lang":
+			lang.Lang = attr.Value
+		case "onlangfailure":
+			lang.OnLangFailure = attr.Value
+		default:
+			lang.Attrs = append(lang.Attrs, attr)
+		}
+	}
+
+	content, err := p.parseContent(decoder, "lang")
+	if err != nil {
+		return nil, err
+	}
+	lang.Content = content
+	lang.End = decoder.position()
+
+	return lang, nil
+}
+
+// parseW 解析 w 和 token 元素
+func (p *Parser) parseW(decoder *tokenDecoder, start xml.StartElement, pos Position) (*W, error) {
+	w := &W{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "role":
 			w.Role = attr.Value
+		default:
+			w.Attrs = append(w.Attrs, attr)
 		}
 	}
 
-	content, err := p.parseContent(decoder, "w")
+	content, err := p.parseContent(decoder, start.Name.Local)
 	if err != nil {
 		return nil, err
 	}
 	w.Content = content
+	w.End = decoder.position()
 
 	return w, nil
 }
 
-// skipElement 跳过未知元素
-func (p *Parser) skipElement(decoder *xml.Decoder, tagName string) (interface{}, error) {
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			return nil, err
+// parseMSTTSExpressAs 解析 mstts:express-as 元素
+func (p *Parser) parseMSTTSExpressAs(decoder *tokenDecoder, start xml.StartElement, pos Position) (*MSTTSExpressAs, error) {
+	expressAs := &MSTTSExpressAs{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "style":
+			expressAs.Style = attr.Value
+		case "styledegree":
+			expressAs.StyleDegree = attr.Value
+		case "role":
+			expressAs.Role = attr.Value
+		default:
+			expressAs.Attrs = append(expressAs.Attrs, attr)
 		}
-		if endElement, ok := token.(xml.EndElement); ok && endElement.Name.Local == tagName {
-			break
+	}
+
+	content, err := p.parseContent(decoder, start.Name.Local)
+	if err != nil {
+		return nil, err
+	}
+	expressAs.Content = content
+	expressAs.End = decoder.position()
+
+	return expressAs, nil
+}
+
+// parseMSTTSSilence 解析 mstts:silence 元素
+func (p *Parser) parseMSTTSSilence(decoder *tokenDecoder, start xml.StartElement, pos Position) (*MSTTSSilence, error) {
+	silence := &MSTTSSilence{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "type":
+			silence.Type = attr.Value
+		case "value":
+			silence.Value = attr.Value
+		default:
+			silence.Attrs = append(silence.Attrs, attr)
+		}
+	}
+
+	// mstts:silence 是空元素
+	if err := p.skipEmptyElement(decoder, start, pos); err != nil {
+		return nil, err
+	}
+	silence.End = decoder.position()
+
+	return silence, nil
+}
+
+// parseAmazonEffect 解析 amazon:effect 元素
+func (p *Parser) parseAmazonEffect(decoder *tokenDecoder, start xml.StartElement, pos Position) (*AmazonEffect, error) {
+	effect := &AmazonEffect{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "name":
+			effect.Name = attr.Value
+		case "phonation":
+			effect.Phonation = attr.Value
+		case "vocal-tract-length":
+			effect.VocalTractLength = attr.Value
+		default:
+			effect.Attrs = append(effect.Attrs, attr)
+		}
+	}
+
+	content, err := p.parseContent(decoder, start.Name.Local)
+	if err != nil {
+		return nil, err
+	}
+	effect.Content = content
+	effect.End = decoder.position()
+
+	return effect, nil
+}
+
+// parseAmazonDomain 解析 amazon:domain 元素
+func (p *Parser) parseAmazonDomain(decoder *tokenDecoder, start xml.StartElement, pos Position) (*AmazonDomain, error) {
+	domain := &AmazonDomain{XMLName: start.Name, Span: Span{Start: pos}}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "name":
+			domain.Name = attr.Value
+		default:
+			domain.Attrs = append(domain.Attrs, attr)
 		}
 	}
-	return nil, nil
+
+	content, err := p.parseContent(decoder, start.Name.Local)
+	if err != nil {
+		return nil, err
+	}
+	domain.Content = content
+	domain.End = decoder.position()
+
+	return domain, nil
 }
 
-// validate 验证解析结果
-func (p *Parser) validate(speak *Speak, result *ParseResult) error {
+// parseUnknown 解析未知元素，保留其名称、属性和子节点
+func (p *Parser) parseUnknown(decoder *tokenDecoder, start xml.StartElement, pos Position) (*UnknownElement, error) {
+	unknown := &UnknownElement{XMLName: start.Name, Span: Span{Start: pos}}
+	unknown.Attrs = append(unknown.Attrs, start.Attr...)
+
+	content, err := p.parseContent(decoder, start.Name.Local)
+	if err != nil {
+		return nil, err
+	}
+	unknown.Content = content
+	unknown.End = decoder.position()
+
+	return unknown, nil
+}
+
+// validate 验证解析结果，ctx 用于时长估算
+func (p *Parser) validate(ctx context.Context, speak *Speak, result *ParseResult) error {
+	var err error
 	if p.config.StrictMode {
-		return p.strictValidation(speak, result)
+		err = p.strictValidation(speak, result)
+	} else {
+		err = p.basicValidation(speak, result)
 	}
-	return p.basicValidation(speak, result)
+	if err != nil {
+		return err
+	}
+	return p.validateDuration(ctx, speak, result)
 }
 
 // basicValidation 基本验证
 func (p *Parser) basicValidation(speak *Speak, result *ParseResult) error {
 	if speak.Version == "" {
-		result.Warnings = append(result.Warnings, "SSML version not specified")
+		p.report(result, Diagnostic{
+			Code:     CodeMissingVersion,
+			Severity: SeverityWarning,
+			Message:  "SSML version not specified",
+			Span:     speak.Span,
+			Node:     speak,
+			Fix:      `add version="1.1" to <speak>`,
+		})
 	}
 
 	if speak.Lang == "" {
-		result.Warnings = append(result.Warnings, "Language not specified")
+		p.report(result, Diagnostic{
+			Code:     CodeMissingLang,
+			Severity: SeverityWarning,
+			Message:  "Language not specified",
+			Span:     speak.Span,
+			Node:     speak,
+			Fix:      `add xml:lang to <speak>, e.g. xml:lang="zh-CN"`,
+		})
 	}
 
+	_ = p.validateLexiconRefs(speak, SeverityWarning, result)
+	_ = p.validateAttributes([]interface{}{speak}, SeverityWarning, result)
+	_ = p.validateLanguages([]interface{}{speak}, SeverityWarning, result)
+	speak.Content, _ = p.validateContentModel("speak", speak.Content, SeverityWarning, result)
+
 	return p.validateNestingDepth(speak.Content, 0, result)
 }
 
 // strictValidation 严格验证
 func (p *Parser) strictValidation(speak *Speak, result *ParseResult) error {
 	if speak.Version == "" {
-		result.Errors = append(result.Errors, "SSML version is required in strict mode")
+		p.report(result, Diagnostic{
+			Code:     CodeMissingVersion,
+			Severity: SeverityError,
+			Message:  "SSML version is required in strict mode",
+			Span:     speak.Span,
+			Node:     speak,
+			Fix:      `add version="1.1" to <speak>`,
+		})
 		return fmt.Errorf("SSML version is required")
 	}
 
 	if speak.Lang == "" {
-		result.Errors = append(result.Errors, "Language is required in strict mode")
+		p.report(result, Diagnostic{
+			Code:     CodeMissingLang,
+			Severity: SeverityError,
+			Message:  "Language is required in strict mode",
+			Span:     speak.Span,
+			Node:     speak,
+			Fix:      `add xml:lang to <speak>, e.g. xml:lang="zh-CN"`,
+		})
 		return fmt.Errorf("language is required")
 	}
 
-	return p.validateNestingDepth(speak.Content, 0, result)
-}
+	if err := p.validateLexiconRefs(speak, SeverityError, result); err != nil {
+		return err
+	}
 
-// validateNestingDepth 验证嵌套深度
-func (p *Parser) validateNestingDepth(content []interface{}, depth int, result *ParseResult) error {
-	if depth > p.config.MaxNestingDepth {
-		err := fmt.Errorf("maximum nesting depth exceeded: %d", p.config.MaxNestingDepth)
-		result.Errors = append(result.Errors, err.Error())
+	if err := p.validateAttributes([]interface{}{speak}, SeverityError, result); err != nil {
 		return err
 	}
 
-	for _, item := range content {
-		if element, ok := item.(SSMLElement); ok {
-			if err := p.validateNestingDepth(element.GetContent(), depth+1, result); err != nil {
-				return err
+	if err := p.validateLanguages([]interface{}{speak}, SeverityError, result); err != nil {
+		return err
+	}
+
+	if _, err := p.validateContentModel("speak", speak.Content, SeverityError, result); err != nil {
+		return err
+	}
+
+	return p.validateNestingDepth(speak.Content, 0, result)
+}
+
+// validateLexiconRefs 验证 lookup 引用的词典是否已用 lexicon 声明
+func (p *Parser) validateLexiconRefs(speak *Speak, severity Severity, result *ParseResult) error {
+	v := p.newValidation(severity, result, (*validation).lexiconRef)
+	for _, item := range speak.Content {
+		if lexicon, ok := item.(*Lexicon); ok {
+			// 没有声明词典时 declared 为 nil，查找总是返回 false
+			if v.declared == nil {
+				v.declared = make(map[string]bool)
 			}
+			v.declared[lexicon.ID] = true
 		}
 	}
+	return v.walk([]interface{}{speak})
+}
 
-	return nil
+// lexiconRef 验证 lookup 引用的词典
+func (v *validation) lexiconRef(node interface{}) {
+	lookup, ok := node.(*Lookup)
+	if !ok || v.declared[lookup.Ref] {
+		return
+	}
+	v.p.report(v.result, Diagnostic{
+		Code:     CodeUnknownLexiconRef,
+		Severity: v.severity,
+		Message:  fmt.Sprintf("lookup references undeclared lexicon '%s'", lookup.Ref),
+		Span:     lookup.Span,
+		Node:     lookup,
+		Fix:      fmt.Sprintf(`declare <lexicon xml:id="%s" uri="..."/> at the start of <speak>`, lookup.Ref),
+	})
+	v.fail(fmt.Errorf("%s: undeclared lexicon: %s", lookup.Start, lookup.Ref))
+}
+
+// validation 用 Walk 对每个节点调用 check 的 Visitor，从 validationPool 中取出，每次解析的验证遍历不分配内存
+type validation struct {
+	p        *Parser
+	severity Severity
+	result   *ParseResult
+	check    func(v *validation, node interface{})
+	declared map[string]bool // lexiconRef 使用的已声明词典
+	err      error           // 严格验证时发现的第一个错误
+}
+
+// validationPool 复用 validation
+var validationPool = sync.Pool{
+	New: func() interface{} { return new(validation) },
+}
+
+// newValidation 返回用 check 验证节点的 validation，用 walk 遍历后放回 validationPool
+func (p *Parser) newValidation(severity Severity, result *ParseResult, check func(v *validation, node interface{})) *validation {
+	v := validationPool.Get().(*validation)
+	*v = validation{p: p, severity: severity, result: result, check: check}
+	return v
 }
 
-// validateTimeAttribute 验证时间属性
-func (p *Parser) validateTimeAttribute(timeStr string) error {
-	if timeStr == "" {
-		return nil
+// walk 验证 nodes 及其所有子节点，返回严格验证时发现的第一个错误
+func (v *validation) walk(nodes []interface{}) error {
+	for _, node := range nodes {
+		Walk(node, v)
 	}
+	err := v.err
+	*v = validation{}
+	validationPool.Put(v)
+	return err
+}
 
-	// 支持的时间格式：1s, 500ms, 1.5s 等
-	if strings.HasSuffix(timeStr, "ms") {
-		_, err := strconv.ParseFloat(strings.TrimSuffix(timeStr, "ms"), 64)
-		return err
+// fail 记录严格验证时发现的第一个错误
+func (v *validation) fail(err error) {
+	if err != nil && v.err == nil && v.severity == SeverityError {
+		v.err = err
 	}
+}
 
-	if strings.HasSuffix(timeStr, "s") {
-		_, err := strconv.ParseFloat(strings.TrimSuffix(timeStr, "s"), 64)
-		return err
+// Enter 验证当前节点
+func (v *validation) Enter(cursor *Cursor) {
+	v.check(v, cursor.Node())
+}
+
+// Leave 实现 Visitor
+func (v *validation) Leave(cursor *Cursor) {}
+
+// validateNestingDepth 验证嵌套深度
+func (p *Parser) validateNestingDepth(content []interface{}, depth int, result *ParseResult) error {
+	for _, item := range content {
+		element, ok := item.(SSMLElement)
+		if !ok {
+			continue
+		}
+
+		if depth+1 > p.config.MaxNestingDepth {
+			span := spanOf(element)
+			p.report(result, Diagnostic{
+				Code:     CodeMaxNestingDepth,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("maximum nesting depth exceeded: %d", p.config.MaxNestingDepth),
+				Span:     span,
+				Node:     element,
+				Fix:      "flatten nested elements or raise ValidationConfig.MaxNestingDepth",
+			})
+			return fmt.Errorf("%s: maximum nesting depth exceeded: %d", span.Start, p.config.MaxNestingDepth)
+		}
+
+		if err := p.validateNestingDepth(element.GetContent(), depth+1, result); err != nil {
+			return err
+		}
 	}
 
-	return fmt.Errorf("invalid time format: %s", timeStr)
+	return nil
+}
+
+// spanOf 返回节点的起止位置，没有位置信息时返回零值
+func spanOf(node interface{}) Span {
+	if positioned, ok := node.(Positioned); ok {
+		return positioned.GetSpan()
+	}
+	return Span{}
+}
+
+// formatAt 在消息前加上位置前缀
+func formatAt(pos Position, msg string) string {
+	if !pos.IsValid() {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", pos, msg)
 }
@@ -359,3 +359,37 @@ func BenchmarkParseFromReader(b *testing.B) {
 		}
 	}
 }
+
+// shortPrompt 在线服务中典型的短提示语
+const shortPrompt = `<speak version="1.0" xml:lang="zh-CN"><voice name="xiaoxiao">您好，<break time="200ms"/>您的验证码是<say-as interpret-as="digits">384912</say-as>，<prosody rate="slow">五分钟内有效</prosody>。</voice></speak>`
+
+// BenchmarkParseShortPrompt 测试解析短提示语的性能和内存分配
+func BenchmarkParseShortPrompt(b *testing.B) {
+	parser := NewParser(nil)
+	b.SetBytes(int64(len(shortPrompt)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse(shortPrompt); err != nil {
+			b.Fatalf("解析失败: %v", err)
+		}
+	}
+}
+
+// BenchmarkParseShortPromptParallel 测试多个 goroutine 共用解析器解析短提示语的性能
+func BenchmarkParseShortPromptParallel(b *testing.B) {
+	parser := NewParser(nil)
+	b.SetBytes(int64(len(shortPrompt)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := parser.Parse(shortPrompt); err != nil {
+				b.Errorf("解析失败: %v", err)
+				return
+			}
+		}
+	})
+}
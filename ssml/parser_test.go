@@ -1,8 +1,19 @@
 package ssml
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 // TestParseBasicSSML 测试基本 SSML 解析
@@ -305,4 +316,2091 @@ func TestParseSerializeRoundtrip(t *testing.T) {
 	if len(result.Root.Content) != len(result2.Root.Content) {
 		t.Errorf("内容元素数量不一致: %d vs %d", len(result.Root.Content), len(result2.Root.Content))
 	}
-} 
\ No newline at end of file
+}
+
+// TestParsePositions 测试节点和诊断信息的源码位置
+func TestParsePositions(t *testing.T) {
+	ssmlContent := "<speak>\n  <p>\n    <break time=\"1s\"/>你好\n  </p>\n</speak>"
+
+	parser := NewParser(nil)
+	result, err := parser.Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	if got := result.Root.Start; got.Line != 1 || got.Column != 1 || got.Offset != 0 {
+		t.Errorf("speak 起始位置错误: %+v", got)
+	}
+	if got := result.Root.End.Offset; got != int64(len(ssmlContent)) {
+		t.Errorf("speak 结束偏移错误: 期望 %d，得到 %d", len(ssmlContent), got)
+	}
+
+	paragraph := result.Root.Content[0].(*Paragraph)
+	if paragraph.Start.String() != "2:3" || paragraph.End.String() != "4:7" {
+		t.Errorf("p 位置错误: %s - %s", paragraph.Start, paragraph.End)
+	}
+
+	breakElem := paragraph.Content[0].(*Break)
+	if breakElem.Start.String() != "3:5" {
+		t.Errorf("break 位置错误: %s", breakElem.Start)
+	}
+
+	text := paragraph.Content[1].(Text)
+	if text.Start.String() != "3:23" {
+		t.Errorf("文本位置错误: %s", text.Start)
+	}
+	if got := ssmlContent[text.Start.Offset:text.End.Offset]; got != "你好" {
+		t.Errorf("文本偏移错误: %q", got)
+	}
+
+	if len(result.Warnings) == 0 || result.Warnings[0].Span.Start.String() != "1:1" {
+		t.Errorf("警告应包含位置: %v", result.Warnings)
+	}
+
+	// 嵌套元素中的错误报告在出错的 token 处，而不是 speak 上
+	result, err = parser.Parse("<speak>\n  <p>\n    <prosody rate=\"slow\">文本</p>\n</speak>")
+	var syntaxErr *xml.SyntaxError
+	if !errors.As(err, &syntaxErr) || len(result.Errors) != 1 {
+		t.Fatalf("不匹配的结束标签应返回语法错误: %v", err)
+	}
+	if d := result.Errors[0]; d.Code != CodeXMLSyntax || d.Span.Start.Line != 3 || d.Span.Start.Column != 32 {
+		t.Errorf("语法错误的诊断错误: %v", d)
+	}
+	if !strings.HasPrefix(err.Error(), "3:32: ") {
+		t.Errorf("返回的错误应包含位置: %v", err)
+	}
+}
+
+// TestParseDiagnostics 测试结构化诊断信息
+func TestParseDiagnostics(t *testing.T) {
+	ssmlContent := `<speak>Hello</speak>`
+
+	parser := NewParser(nil)
+	result, err := parser.Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	counts := result.CountByCode()
+	if counts[CodeMissingVersion] != 1 || counts[CodeMissingLang] != 1 {
+		t.Errorf("诊断统计错误: %v", counts)
+	}
+
+	d := result.DiagnosticsByCode(CodeMissingVersion)[0]
+	if d.Severity != SeverityWarning || d.Node != result.Root || d.Fix == "" {
+		t.Errorf("诊断内容错误: %+v", d)
+	}
+	if got := d.String(); got != "1:1: SSML001 missing-version: SSML version not specified" {
+		t.Errorf("诊断格式错误: %s", got)
+	}
+
+	// 抑制指定代码
+	config := DefaultValidationConfig()
+	config.SuppressCodes = []DiagnosticCode{CodeMissingLang}
+	result, err = NewParser(config).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(result.DiagnosticsByCode(CodeMissingLang)) != 0 {
+		t.Error("期望 SSML002 被抑制")
+	}
+}
+
+// TestParseSayAs 测试 say-as 元素的解析、序列化和音频处理
+func TestParseSayAs(t *testing.T) {
+	ssmlContent := `<speak version="1.0" xml:lang="zh-CN">日期：<say-as interpret-as="date" format="ymd" detail="1">2024-01-02</say-as></speak>`
+
+	parser := NewParser(nil)
+	result, err := parser.Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	if len(result.Root.Content) != 2 {
+		t.Fatalf("期望 2 个内容元素，得到 %d", len(result.Root.Content))
+	}
+	sayAs, ok := result.Root.Content[1].(*SayAs)
+	if !ok {
+		t.Fatalf("期望 *SayAs，得到 %T", result.Root.Content[1])
+	}
+	if sayAs.InterpretAs != "date" || sayAs.Format != "ymd" || sayAs.Detail != "1" {
+		t.Errorf("say-as 属性错误: %+v", sayAs)
+	}
+
+	serialized, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	if !strings.Contains(serialized, `<say-as interpret-as="date" format="ymd" detail="1">2024-01-02</say-as>`) {
+		t.Errorf("序列化结果缺少 say-as: %s", serialized)
+	}
+
+	audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	props := audioResult.Segments[1].Properties
+	if props.InterpretAs != "date" || props.Format != "ymd" || props.Detail != "1" {
+		t.Errorf("音频片段属性缺少 say-as 信息: %+v", props)
+	}
+	if audioResult.Segments[0].Properties.InterpretAs != "" {
+		t.Error("say-as 之外的片段不应带有读法信息")
+	}
+}
+
+// TestParseMark 测试 mark 元素的解析、序列化和书签定位
+func TestParseMark(t *testing.T) {
+	ssmlContent := `<speak version="1.0" xml:lang="zh-CN">你好<mark name="m1"/>世界<break time="1s"/><mark name="m2"/>再见</speak>`
+
+	parser := NewParser(nil)
+	result, err := parser.Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	mark, ok := result.Root.Content[1].(*Mark)
+	if !ok || mark.Name != "m1" {
+		t.Fatalf("期望 mark m1，得到 %#v", result.Root.Content[1])
+	}
+
+	serialized, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	if !strings.Contains(serialized, `你好<mark name="m1"/>世界`) {
+		t.Errorf("序列化结果缺少 mark: %s", serialized)
+	}
+
+	audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	marks := audioResult.GetMarkInstructions()
+	if len(marks) != 2 {
+		t.Fatalf("期望 2 个书签指令，得到 %d", len(marks))
+	}
+	if marks[0].Position != len("你好") || marks[0].StartTime != audioResult.Segments[0].Duration {
+		t.Errorf("书签 m1 位置错误: %+v", marks[0])
+	}
+
+	processor := NewCompleteSSMLToAudioProcessor(NewMockTTSAdapter(16000), 16000)
+	audioData, _, err := processor.ProcessSSMLToAudio(ssmlContent)
+	if err != nil {
+		t.Fatalf("音频生成失败: %v", err)
+	}
+	if len(audioData.Marks) != 2 {
+		t.Fatalf("期望 2 个书签，得到 %d", len(audioData.Marks))
+	}
+	// 6 个字符共 600ms 语音，m1 位于前两个字之后
+	if audioData.Marks[0].Name != "m1" || audioData.Marks[0].SampleOffset != 3200 {
+		t.Errorf("书签 m1 偏移错误: %+v", audioData.Marks[0])
+	}
+	// m2 位于“世界”和 1s 停顿之后、“再见”开始的位置
+	if audioData.Marks[1].Name != "m2" || audioData.Marks[1].SampleOffset != 6400+16000 {
+		t.Errorf("书签 m2 偏移错误: %+v", audioData.Marks[1])
+	}
+}
+
+// TestProcessTTSAudioBreakPlacement 测试两种后处理入口的停顿位置
+func TestProcessTTSAudioBreakPlacement(t *testing.T) {
+	text := "一二三四五六七八九十"
+	tts := &AudioData{SampleRate: 1000, Channels: 1, Data: make([]float64, 1000)}
+	for i := range tts.Data {
+		tts.Data[i] = 1
+	}
+	// “一二三四”之后 200ms 的停顿
+	instructions := []AudioInstruction{{Type: "break", Position: len("一二三四"), Duration: 200 * time.Millisecond}}
+	silenceAt := func(audio *AudioData) int {
+		for i, sample := range audio.Data {
+			if sample == 0 {
+				return i
+			}
+		}
+		return -1
+	}
+
+	postProcessor := NewAudioPostProcessor(1000)
+	// ProcessTTSAudio 保持原来的行为：停顿之前复制与停顿等长的语音
+	audio, err := postProcessor.ProcessTTSAudio(tts, instructions)
+	if err != nil {
+		t.Fatalf("后处理失败: %v", err)
+	}
+	if at := silenceAt(audio); at != 200 || len(audio.Data) != 1200 {
+		t.Errorf("ProcessTTSAudio 的静音应从 200 开始: %d, 共 %d", at, len(audio.Data))
+	}
+
+	// ProcessTTSAudioWithText 按文本位置插入静音
+	audio, err = postProcessor.ProcessTTSAudioWithText(tts, instructions, text)
+	if err != nil {
+		t.Fatalf("后处理失败: %v", err)
+	}
+	if at := silenceAt(audio); at != 400 || len(audio.Data) != 1200 {
+		t.Errorf("ProcessTTSAudioWithText 的静音应从 400 开始: %d, 共 %d", at, len(audio.Data))
+	}
+
+	// ProcessSSMLToAudio 使用按文本定位的方式
+	processor := NewCompleteSSMLToAudioProcessor(NewMockTTSAdapter(1000), 1000)
+	audio, _, err = processor.ProcessSSMLToAudio(`<speak version="1.0" xml:lang="zh-CN">一二三四<break time="200ms"/>五六七八九十</speak>`)
+	if err != nil {
+		t.Fatalf("音频生成失败: %v", err)
+	}
+	if len(audio.Data) != 1200 || audio.Data[399] == 0 || audio.Data[400] != 0 || audio.Data[599] != 0 {
+		t.Errorf("ProcessSSMLToAudio 的静音应位于 400 到 600: 共 %d", len(audio.Data))
+	}
+}
+
+// TestLexiconLookup 测试发音词典的解析和应用
+func TestLexiconLookup(t *testing.T) {
+	pls := `<?xml version="1.0" encoding="UTF-8"?>
+<lexicon version="1.0" xmlns="http://www.w3.org/2005/01/pronunciation-lexicon" alphabet="ipa" xml:lang="en-US">
+  <lexeme>
+    <grapheme>Acme</grapheme>
+    <phoneme>ˈækmi</phoneme>
+  </lexeme>
+  <lexeme>
+    <grapheme>W3C</grapheme>
+    <alias>World Wide Web Consortium</alias>
+  </lexeme>
+</lexicon>`
+
+	ssmlContent := `<speak version="1.1" xml:lang="en-US">
+  <lexicon uri="brands.pls" xml:id="brands"/>
+  <lookup ref="brands">Acme joins W3C, not Acmeville.</lookup>
+  Acme again.
+</speak>`
+
+	result, err := NewParser(nil).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if lexicon, ok := result.Root.Content[0].(*Lexicon); !ok || lexicon.ID != "brands" || lexicon.URI != "brands.pls" {
+		t.Fatalf("lexicon 解析错误: %#v", result.Root.Content[0])
+	}
+	if len(result.DiagnosticsByCode(CodeUnknownLexiconRef)) != 0 {
+		t.Errorf("不应有未声明词典的诊断: %v", result.Warnings)
+	}
+
+	processor := NewAudioProcessor()
+	processor.SetLexiconRegistry(NewLexiconRegistry(MemoryLexiconLoader{"brands.pls": pls}))
+	audioResult, err := processor.ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+
+	if !strings.Contains(audioResult.PlainText, "World Wide Web Consortium") {
+		t.Errorf("alias 未生效: %s", audioResult.PlainText)
+	}
+	if !strings.Contains(audioResult.PlainText, "Acmeville") {
+		t.Errorf("不应替换单词的一部分: %s", audioResult.PlainText)
+	}
+
+	phonemeSegments := 0
+	for _, segment := range audioResult.Segments {
+		if segment.Properties.Phoneme == "ˈækmi" {
+			phonemeSegments++
+			if segment.Text != "Acme" || segment.Properties.Alphabet != "ipa" {
+				t.Errorf("phoneme 片段错误: %+v", segment)
+			}
+		}
+	}
+	// lookup 之外的 Acme 不应用词典
+	if phonemeSegments != 1 {
+		t.Errorf("期望 1 个带音标的片段，得到 %d", phonemeSegments)
+	}
+
+	// 声明了 xml:lang 的词典只用于该语言范围内的文本；文档没有声明语言时总是适用
+	for input, want := range map[string]string{
+		`<speak version="1.1" xml:lang="en-US"><lexicon uri="brands.pls" xml:id="b"/><lookup ref="b"><lang xml:lang="zh-CN">W3C</lang> W3C</lookup></speak>`: "W3C World Wide Web Consortium",
+		`<speak version="1.1"><lexicon uri="brands.pls" xml:id="b"/><lookup ref="b">W3C</lookup></speak>`:                                                    "World Wide Web Consortium",
+		`<speak version="1.1"><lexicon uri="brands.pls" xml:id="b"/><lookup ref="b"><voice xml:lang="zh-CN">W3C</voice></lookup></speak>`:                    "W3C",
+	} {
+		parsed, err := NewParser(nil).Parse(input)
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+		if scoped, err := processor.ProcessSSML(parsed.Root); err != nil || scoped.PlainText != want {
+			t.Errorf("词典的语言范围错误: %v %q，期望 %q", err, scoped.PlainText, want)
+		}
+	}
+
+	// 无法加载的词典应返回错误
+	processor.SetLexiconRegistry(NewLexiconRegistry(MemoryLexiconLoader{}))
+	if _, err := processor.ProcessSSML(result.Root); err == nil {
+		t.Error("期望词典加载失败")
+	}
+
+	// 引用未声明的词典应产生警告
+	result, err = NewParser(nil).Parse(`<speak version="1.1" xml:lang="en-US"><lookup ref="missing">x</lookup></speak>`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(result.DiagnosticsByCode(CodeUnknownLexiconRef)) != 1 {
+		t.Errorf("期望未声明词典的警告: %v", result.Warnings)
+	}
+
+	// 文件加载器只读取 BaseDir 中的文件
+	dir := t.TempDir()
+	base := filepath.Join(dir, "lexicons")
+	if err := os.MkdirAll(filepath.Join(base, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{filepath.Join(base, "brands.pls"): pls, filepath.Join(dir, "secret.pls"): pls} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loader := &FileLexiconLoader{BaseDir: base}
+	for _, uri := range []string{"brands.pls", "sub/../brands.pls", "file://brands.pls"} {
+		file, err := loader.Load(uri)
+		if err != nil {
+			t.Errorf("%s: 应能加载 BaseDir 中的词典: %v", uri, err)
+			continue
+		}
+		file.Close()
+	}
+	for _, uri := range []string{"../secret.pls", "sub/../../secret.pls", filepath.Join(dir, "secret.pls"), "file://" + filepath.ToSlash(filepath.Join(dir, "secret.pls")), ""} {
+		if file, err := loader.Load(uri); err == nil {
+			file.Close()
+			t.Errorf("%s: 不应加载 BaseDir 之外的文件", uri)
+		}
+	}
+
+	// 加载时不持有锁：慢的词典不阻塞其他 URI，Load 中可以解析其他 URI，同一 URI 只加载一次
+	release := make(chan struct{})
+	started := make(chan string, 10)
+	var registry *LexiconRegistry
+	registry = NewLexiconRegistry(LexiconLoaderFunc(func(uri string) (io.ReadCloser, error) {
+		started <- uri
+		switch uri {
+		case "slow.pls":
+			<-release
+		case "outer.pls":
+			if _, err := registry.Resolve("brands.pls"); err != nil {
+				return nil, err
+			}
+		}
+		return io.NopCloser(strings.NewReader(pls)), nil
+	}))
+	slow := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := registry.Resolve("slow.pls")
+			slow <- err
+		}()
+	}
+	if uri := <-started; uri != "slow.pls" {
+		t.Fatalf("应先加载 slow.pls: %s", uri)
+	}
+	if _, err := registry.Resolve("outer.pls"); err != nil {
+		t.Errorf("加载其他词典不应等待 slow.pls: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := registry.ResolveContext(cancelled, "slow.pls"); !errors.Is(err, context.Canceled) {
+		t.Errorf("等待加载时应响应取消: %v", err)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-slow; err != nil {
+			t.Errorf("加载 slow.pls 失败: %v", err)
+		}
+	}
+	close(started)
+	loads := 0
+	for uri := range started {
+		if uri == "slow.pls" {
+			loads++
+		}
+	}
+	if loads != 0 {
+		t.Errorf("同一 URI 应只加载一次，又加载了 %d 次", loads)
+	}
+}
+
+// TestParseMetadata 测试 meta、metadata 和 desc 元素的保留
+func TestParseMetadata(t *testing.T) {
+	metadataXML := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description about="book" author="张三"/></rdf:RDF>`
+	ssmlContent := `<speak version="1.1" xml:lang="zh-CN">` +
+		`<meta name="seeAlso" content="http://example.com/book.xml"/>` +
+		`<metadata>` + metadataXML + `</metadata>` +
+		`<audio src="bell.wav"><desc xml:lang="zh-CN">铃声</desc>备用文本</audio>正文` +
+		`</speak>`
+
+	result, err := NewParser(nil).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	meta, ok := result.Root.Content[0].(*Meta)
+	if !ok || meta.Name != "seeAlso" || meta.Value != "http://example.com/book.xml" {
+		t.Errorf("meta 解析错误: %#v", result.Root.Content[0])
+	}
+	metadata, ok := result.Root.Content[1].(*Metadata)
+	if !ok || metadata.InnerXML != metadataXML {
+		t.Errorf("metadata 解析错误: %#v", result.Root.Content[1])
+	}
+
+	serialized, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	for _, want := range []string{
+		`<meta name="seeAlso" content="http://example.com/book.xml"/>`,
+		`<metadata>` + metadataXML + `</metadata>`,
+		`<desc xml:lang="zh-CN">铃声</desc>`,
+	} {
+		if !strings.Contains(serialized, want) {
+			t.Errorf("序列化结果缺少 %s: %s", want, serialized)
+		}
+	}
+
+	audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	if audioResult.PlainText != "备用文本正文" {
+		t.Errorf("纯文本不应包含元数据: %s", audioResult.PlainText)
+	}
+
+	// speak 上声明的前缀、注释和转义的字符保留在内部 XML 中
+	inner := `<dc:title xml:lang="zh">书名 &amp; 副标题</dc:title><!-- 作者 --><dc:creator/>`
+	result, err = NewParser(nil).Parse(`<speak version="1.1" xml:lang="zh-CN" xmlns:dc="http://purl.org/dc/elements/1.1/"><metadata>` + inner + `</metadata></speak>`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if metadata, ok := result.Root.Content[0].(*Metadata); !ok || metadata.InnerXML != inner {
+		t.Errorf("metadata 内部 XML 错误: %#v", result.Root.Content[0])
+	}
+}
+
+// TestParseTokenAndLang 测试 SSML 1.1 的 token、lang 元素和 onlangfailure 属性
+func TestParseTokenAndLang(t *testing.T) {
+	ssmlContent := `<speak version="1.1" xml:lang="zh-CN" onlangfailure="ignorelang">` +
+		`<voice name="xiaoxiao">你好<lang xml:lang="en-US" onlangfailure="changevoice"><token role="noun">world</token></lang></voice>` +
+		`</speak>`
+
+	result, err := NewParser(nil).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if result.Root.OnLangFailure != "ignorelang" {
+		t.Errorf("speak onlangfailure 错误: %s", result.Root.OnLangFailure)
+	}
+
+	voice := result.Root.Content[0].(*Voice)
+	lang, ok := voice.Content[1].(*Lang)
+	if !ok || lang.Lang != "en-US" || lang.OnLangFailure != "changevoice" {
+		t.Fatalf("lang 解析错误: %#v", voice.Content[1])
+	}
+	token, ok := lang.Content[0].(*W)
+	if !ok || token.XMLName.Local != "token" || token.Role != "noun" {
+		t.Fatalf("token 解析错误: %#v", lang.Content[0])
+	}
+
+	serialized, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	if !strings.Contains(serialized, `<lang xml:lang="en-US" onlangfailure="changevoice"><token role="noun">world</token></lang>`) {
+		t.Errorf("序列化结果错误: %s", serialized)
+	}
+
+	audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	inner := audioResult.Segments[1].Properties
+	if inner.Language != "en-US" || inner.Voice != "xiaoxiao" || inner.OnLangFailure != "changevoice" {
+		t.Errorf("lang 内的片段属性错误: %+v", inner)
+	}
+	if outer := audioResult.Segments[0].Properties; outer.Language == "en-US" || outer.OnLangFailure != "ignorelang" {
+		t.Errorf("lang 外的片段属性错误: %+v", outer)
+	}
+}
+
+// TestParseUnknownRoundtrip 测试未知元素和属性的无损往返
+func TestParseUnknownRoundtrip(t *testing.T) {
+	ssmlContent := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-US">` +
+		`<voice name="x" data-id="7"><vendor:fx xmlns:vendor="urn:vendor" vendor:level="2" type="echo">Hi</vendor:fx></voice>` +
+		`</speak>`
+
+	result, err := NewParser(nil).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	voice := result.Root.Content[0].(*Voice)
+	if len(voice.Attrs) != 1 || voice.Attrs[0].Name.Local != "data-id" {
+		t.Errorf("voice 未识别属性错误: %v", voice.Attrs)
+	}
+	unknown, ok := voice.Content[0].(*UnknownElement)
+	if !ok {
+		t.Fatalf("期望 *UnknownElement，得到 %T", voice.Content[0])
+	}
+	if unknown.XMLName.Space != "urn:vendor" || unknown.XMLName.Local != "fx" || len(unknown.Attrs) != 3 {
+		t.Errorf("未知元素错误: %+v", unknown)
+	}
+
+	serialized, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<speak version="1.0" xml:lang="en-US" xmlns="http://www.w3.org/2001/10/synthesis">` +
+		`<voice name="x" data-id="7"><vendor:fx xmlns:vendor="urn:vendor" vendor:level="2" type="echo">Hi</vendor:fx></voice>` +
+		`</speak>`
+	if serialized != want {
+		t.Errorf("往返结果不一致:\n期望 %s\n得到 %s", want, serialized)
+	}
+
+	audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	if audioResult.PlainText != "Hi" {
+		t.Errorf("未知元素的内容应参与合成: %s", audioResult.PlainText)
+	}
+}
+
+// TestParseVendorExtensions 测试按命名空间解析厂商扩展元素
+func TestParseVendorExtensions(t *testing.T) {
+	ssmlContent := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="zh-CN">` +
+		`<mstts:express-as style="cheerful" styledegree="2">你好<mstts:silence type="Sentenceboundary" value="200ms"/></mstts:express-as>` +
+		`<amazon:effect name="whispered">secret</amazon:effect>` +
+		`<amazon:domain name="news">headline</amazon:domain>` +
+		`<foo:break xmlns:foo="urn:foo" time="1s"/>` +
+		`</speak>`
+
+	result, err := NewParser(nil).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	if len(result.Root.Namespaces) != 2 || result.Root.Namespaces[1] != (Namespace{Prefix: "mstts", URI: MSTTSNamespace}) {
+		t.Errorf("命名空间声明错误: %+v", result.Root.Namespaces)
+	}
+	expressAs, ok := result.Root.Content[0].(*MSTTSExpressAs)
+	if !ok {
+		t.Fatalf("期望 *MSTTSExpressAs，得到 %T", result.Root.Content[0])
+	}
+	if expressAs.Style != "cheerful" || expressAs.StyleDegree != "2" {
+		t.Errorf("express-as 属性错误: %+v", expressAs)
+	}
+	if silence, ok := expressAs.Content[1].(*MSTTSSilence); !ok || silence.Value != "200ms" {
+		t.Errorf("silence 解析错误: %+v", expressAs.Content[1])
+	}
+	if effect, ok := result.Root.Content[1].(*AmazonEffect); !ok || effect.Name != "whispered" {
+		t.Errorf("amazon:effect 解析错误: %+v", result.Root.Content[1])
+	}
+	if domain, ok := result.Root.Content[2].(*AmazonDomain); !ok || domain.Name != "news" {
+		t.Errorf("amazon:domain 解析错误: %+v", result.Root.Content[2])
+	}
+	if _, ok := result.Root.Content[3].(*UnknownElement); !ok {
+		t.Errorf("其他命名空间的 break 应为未知元素，得到 %T", result.Root.Content[3])
+	}
+
+	serialized, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<speak version="1.0" xml:lang="zh-CN" xmlns="http://www.w3.org/2001/10/synthesis" xmlns:mstts="https://www.w3.org/2001/mstts">` +
+		`<mstts:express-as style="cheerful" styledegree="2">你好<mstts:silence type="Sentenceboundary" value="200ms"/></mstts:express-as>` +
+		`<amazon:effect name="whispered">secret</amazon:effect>` +
+		`<amazon:domain name="news">headline</amazon:domain>` +
+		`<foo:break xmlns:foo="urn:foo" time="1s"/>` +
+		`</speak>`
+	if serialized != want {
+		t.Errorf("往返结果不一致:\n期望 %s\n得到 %s", want, serialized)
+	}
+
+	built, err := NewBuilder().Version("1.0").ExpressAsText("sad", "再见").BuildString(false)
+	if err != nil {
+		t.Fatalf("构建失败: %v", err)
+	}
+	if !strings.Contains(built, `<mstts:express-as xmlns:mstts="https://www.w3.org/2001/mstts" style="sad">再见</mstts:express-as>`) {
+		t.Errorf("构建结果错误: %s", built)
+	}
+
+	audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	if props := audioResult.Segments[0].Properties; props.Style != "cheerful" || props.StyleDegree != "2" {
+		t.Errorf("express-as 片段属性错误: %+v", props)
+	}
+	if props := audioResult.Segments[1].Properties; props.Effect != "whispered" || props.Style != "" {
+		t.Errorf("amazon:effect 片段属性错误: %+v", props)
+	}
+	if props := audioResult.Segments[2].Properties; props.Domain != "news" {
+		t.Errorf("amazon:domain 片段属性错误: %+v", props)
+	}
+
+	// Google 的扩展没有类型化的节点，作为未知元素保留，内容照常合成
+	google := `<speak version="1.0" xml:lang="en-US"><google:style name="lively">Hi</google:style><par><media begin="0.5s">there</media></par></speak>`
+	result, err = NewParser(nil).Parse(google)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if style, ok := result.Root.Content[0].(*UnknownElement); !ok || style.XMLName.Space != "google" || style.XMLName.Local != "style" || len(style.Attrs) != 1 || style.Attrs[0].Value != "lively" {
+		t.Errorf("google:style 应为未知元素: %#v", result.Root.Content[0])
+	}
+	if _, ok := result.Root.Content[1].(*UnknownElement); !ok {
+		t.Errorf("par 应为未知元素: %#v", result.Root.Content[1])
+	}
+	if serialized, _ := NewSerializer(false).Serialize(result.Root); !strings.HasSuffix(serialized, google) {
+		t.Errorf("Google 扩展往返结果不一致: %s", serialized)
+	}
+	if audio, err := NewAudioProcessor().ProcessSSML(result.Root); err != nil || !strings.HasPrefix(audio.PlainText, "Hi") || !strings.HasSuffix(audio.PlainText, "there") {
+		t.Errorf("Google 扩展中的文本应参与合成: %v %+v", err, audio)
+	}
+	if report := result.Root.ValidateFor(GoogleProfile, nil); len(report.DiagnosticsByCode(CodeUnsupportedElement)) != 0 {
+		t.Errorf("GoogleProfile 应接受 Google 扩展: %s", report.FormatDiagnostics())
+	}
+
+	strict := NewParser(&ValidationConfig{AllowUnknownElements: false})
+	if _, err := strict.Parse(`<speak version="1.0" xml:lang="zh-CN"><mstts:backgroundaudio xmlns:mstts="http://www.w3.org/2001/mstts"/></speak>`); err == nil || !strings.Contains(err.Error(), "mstts:backgroundaudio") {
+		t.Errorf("应报告带前缀的未知元素: %v", err)
+	}
+}
+
+// testSFX 测试用的自定义音效元素
+type testSFX struct {
+	Src string
+	Span
+}
+
+func (e *testSFX) ElementName() xml.Name { return xml.Name{Space: "urn:ours", Local: "sfx"} }
+
+// testChapter 测试用的自定义章节元素
+type testChapter struct {
+	Title   string
+	Content []interface{}
+	Span
+}
+
+func (e *testChapter) ElementName() xml.Name { return xml.Name{Space: "urn:ours", Local: "chapter"} }
+
+// TestElementRegistry 测试自定义元素注册表
+func TestElementRegistry(t *testing.T) {
+	registry := NewElementRegistry()
+	registry.Register(xml.Name{Space: "urn:ours", Local: "sfx"}, ElementHandler{
+		Parse: func(ctx *ElementParseContext, start xml.StartElement) (interface{}, error) {
+			sfx := &testSFX{}
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "src" {
+					sfx.Src = attr.Value
+				}
+			}
+			if sfx.Src == "" {
+				return nil, fmt.Errorf("missing src")
+			}
+			err := ctx.Skip()
+			sfx.Span = ctx.Span()
+			return sfx, err
+		},
+		Serialize: func(ctx *ElementSerializeContext, node interface{}) error {
+			sfx := node.(*testSFX)
+			return ctx.WriteElement(sfx.ElementName(), []xml.Attr{{Name: xml.Name{Local: "src"}, Value: sfx.Src}}, nil)
+		},
+		Process: func(ctx *ElementProcessContext, node interface{}) {
+			ctx.AddInstruction(AudioInstruction{Type: "audio", AudioFile: node.(*testSFX).Src, Duration: time.Second})
+		},
+	})
+	registry.Register(xml.Name{Space: "urn:ours", Local: "chapter"}, ElementHandler{
+		Parse: func(ctx *ElementParseContext, start xml.StartElement) (interface{}, error) {
+			chapter := &testChapter{}
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "title" {
+					chapter.Title = attr.Value
+				}
+			}
+			content, err := ctx.ParseContent()
+			chapter.Content = content
+			chapter.Span = ctx.Span()
+			return chapter, err
+		},
+		Serialize: func(ctx *ElementSerializeContext, node interface{}) error {
+			chapter := node.(*testChapter)
+			return ctx.WriteElement(chapter.ElementName(), []xml.Attr{{Name: xml.Name{Local: "title"}, Value: chapter.Title}}, chapter.Content)
+		},
+		Process: func(ctx *ElementProcessContext, node interface{}) {
+			props := ctx.Properties()
+			props.Voice = "narrator"
+			ctx.ProcessContent(node.(*testChapter).Content, props)
+		},
+	})
+
+	ssmlContent := `<speak version="1.0" xml:lang="zh-CN" xmlns:ours="urn:ours">` +
+		`<ours:chapter title="一"><ours:sfx src="bell.wav"/>第一章<break time="1s"/></ours:chapter>` +
+		`</speak>`
+
+	parser := NewParser(nil)
+	parser.SetElementRegistry(registry)
+	result, err := parser.Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	chapter, ok := result.Root.Content[0].(*testChapter)
+	if !ok {
+		t.Fatalf("期望 *testChapter，得到 %T", result.Root.Content[0])
+	}
+	if chapter.Title != "一" || len(chapter.Content) != 3 || !chapter.End.IsValid() {
+		t.Errorf("chapter 解析错误: %+v", chapter)
+	}
+	if sfx, ok := chapter.Content[0].(*testSFX); !ok || sfx.Src != "bell.wav" {
+		t.Errorf("sfx 解析错误: %+v", chapter.Content[0])
+	}
+
+	serializer := NewSerializer(false)
+	serializer.Registry = registry
+	serialized, err := serializer.Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	want := `<ours:chapter title="一"><ours:sfx src="bell.wav"/>第一章<break time="1s"/></ours:chapter>`
+	if !strings.Contains(serialized, want) {
+		t.Errorf("序列化结果错误: %s", serialized)
+	}
+
+	processor := NewAudioProcessor()
+	processor.SetElementRegistry(registry)
+	audioResult, err := processor.ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	if len(audioResult.Segments) != 1 || audioResult.Segments[0].Properties.Voice != "narrator" {
+		t.Errorf("chapter 内的片段错误: %+v", audioResult.Segments)
+	}
+	if audioResult.Segments[0].StartTime != time.Second {
+		t.Errorf("sfx 之后的文本应从 1s 开始: %v", audioResult.Segments[0].StartTime)
+	}
+	if instr := audioResult.Instructions[0]; instr.Type != "audio" || instr.AudioFile != "bell.wav" {
+		t.Errorf("sfx 指令错误: %+v", instr)
+	}
+
+	// 未注册时作为未知元素保留
+	result, err = NewParser(nil).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if _, ok := result.Root.Content[0].(*UnknownElement); !ok {
+		t.Errorf("未注册的元素应为未知元素，得到 %T", result.Root.Content[0])
+	}
+
+	if _, err := parser.Parse(`<speak version="1.0" xml:lang="zh-CN" xmlns:ours="urn:ours"><ours:sfx/></speak>`); err == nil || !strings.Contains(err.Error(), "missing src") {
+		t.Errorf("应返回自定义解析错误: %v", err)
+	}
+
+	// 处理函数没有读取到结束标签时跳过剩余内容并报告
+	registry.Register(xml.Name{Space: "urn:ours", Local: "mark"}, ElementHandler{
+		Parse: func(ctx *ElementParseContext, start xml.StartElement) (interface{}, error) {
+			return &testSFX{Src: "mark"}, nil
+		},
+	})
+	result, err = parser.Parse(`<speak version="1.0" xml:lang="zh-CN" xmlns:ours="urn:ours">` +
+		`<ours:mark><break time="1s"/>内部</ours:mark>之后</speak>`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(result.Root.Content) != 2 || result.Root.Content[1].(Text).Content != "之后" {
+		t.Errorf("元素之后的内容解析错误: %+v", result.Root.Content)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != CodeUnconsumedElement || result.Warnings[0].Span.Start.Column != 61 {
+		t.Errorf("应报告未读取到结束标签: %+v", result.Warnings)
+	}
+}
+
+// TestWhitespaceModes 测试文本空白处理模式和纯文本的词边界
+func TestWhitespaceModes(t *testing.T) {
+	ssmlContent := `<speak version="1.0" xml:lang="en-US">Hello <emphasis>big</emphasis>  world` +
+		`<p xml:space="preserve">a  b</p><s>你好</s><s>世界</s></speak>`
+
+	tests := []struct {
+		mode      WhitespaceMode
+		firstText string
+		plainText string
+	}{
+		{WhitespaceTrim, "Hello", "Hello big world a  b你好世界"},
+		{WhitespaceNormalize, "Hello ", "Hello big world a  b你好世界"},
+		{WhitespacePreserve, "Hello ", "Hello big world a  b你好世界"},
+	}
+
+	for _, tt := range tests {
+		parser := NewParser(&ValidationConfig{AllowUnknownElements: true, MaxNestingDepth: 10, Whitespace: tt.mode})
+		result, err := parser.Parse(ssmlContent)
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+
+		if text := result.Root.Content[0].(Text); text.Content != tt.firstText {
+			t.Errorf("模式 %d: 第一段文本错误: %q", tt.mode, text.Content)
+		}
+		paragraph := result.Root.Content[3].(*Paragraph)
+		if text := paragraph.Content[0].(Text); text.Content != "a  b" {
+			t.Errorf("模式 %d: xml:space=\"preserve\" 应保留空白: %q", tt.mode, text.Content)
+		}
+
+		audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+		if err != nil {
+			t.Fatalf("音频处理失败: %v", err)
+		}
+		if audioResult.PlainText != tt.plainText {
+			t.Errorf("模式 %d: 纯文本错误: %q", tt.mode, audioResult.PlainText)
+		}
+	}
+
+	result, err := NewParser(&ValidationConfig{AllowUnknownElements: true, MaxNestingDepth: 10, Whitespace: WhitespaceNormalize}).Parse(`<speak version="1.0" xml:lang="en-US"><w>un</w><w>real</w>  <w>life</w></speak>`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if text, ok := result.Root.Content[2].(Text); !ok || text.Content != " " {
+		t.Errorf("元素之间的空白应规范化为一个空格: %+v", result.Root.Content)
+	}
+
+	// 保留了空白的模式只在源文本有空白处分词，去掉空白的模式在相邻单词之间补上空格
+	boundaries := []struct {
+		mode      WhitespaceMode
+		plainText string
+	}{
+		{WhitespaceTrim, "un believ able"},
+		{WhitespaceNormalize, "unbelievable"},
+		{WhitespacePreserve, "unbelievable"},
+	}
+	for _, tt := range boundaries {
+		parser := NewParser(&ValidationConfig{MaxNestingDepth: 10, Whitespace: tt.mode})
+		result, err := parser.Parse(`<speak version="1.0" xml:lang="en-US">un<emphasis>believ</emphasis>able</speak>`)
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+		audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+		if err != nil {
+			t.Fatalf("音频处理失败: %v", err)
+		}
+		if audioResult.PlainText != tt.plainText {
+			t.Errorf("模式 %d: 元素边界没有空白时纯文本错误: %q", tt.mode, audioResult.PlainText)
+		}
+	}
+}
+
+// TestParseRecover 测试恢复模式修复格式错误的输入
+func TestParseRecover(t *testing.T) {
+	ssmlContent := `Tom & Jerry&nbsp;say <emphasis>hi<break time="1s"></p> a < b &brand;`
+
+	config := DefaultValidationConfig()
+	config.Recover = true
+	config.Entities = map[string]string{"brand": "A&B"}
+
+	result, err := NewParser(config).Parse(ssmlContent)
+	if err != nil {
+		t.Fatalf("恢复模式解析失败: %v", err)
+	}
+
+	counts := result.CountByCode()
+	if counts[CodeRepairedEntity] != 3 || counts[CodeEscapedMarkup] != 1 || counts[CodeStrayEndTag] != 1 ||
+		counts[CodeAutoClosedElement] != 2 || counts[CodeWrappedRoot] != 1 {
+		t.Errorf("修复记录错误:\n%s", result.FormatDiagnostics())
+	}
+	for _, d := range result.Warnings {
+		if d.Code == CodeStrayEndTag && d.Span.Start.String() != "1:51" {
+			t.Errorf("修复位置错误: %s", d)
+		}
+	}
+
+	audioResult, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	if audioResult.PlainText != "Tom & Jerry say hi a < b A&B" {
+		t.Errorf("纯文本错误: %q", audioResult.PlainText)
+	}
+
+	if _, err := NewParser(nil).Parse(ssmlContent); err == nil {
+		t.Error("非恢复模式应返回错误")
+	}
+
+	// 节点的位置对应原始输入，不受修复时插入的文本影响
+	result, err = NewParser(config).Parse("Tom & Jerry <break time=\"1s\"/> end")
+	if err != nil {
+		t.Fatalf("恢复模式解析失败: %v", err)
+	}
+	if br, ok := result.Root.Content[1].(*Break); !ok || br.Start.String() != "1:13" || br.End.String() != "1:31" {
+		t.Errorf("break 位置应对应原始输入: %+v", result.Root.Content[1])
+	}
+	if text := result.Root.Content[2].(Text); text.Start.String() != "1:32" || result.Root.Start.String() != "1:1" {
+		t.Errorf("文本位置应对应原始输入: %+v %v", text.Span, result.Root.Span)
+	}
+	result, err = NewParser(config).Parse("<?xml version=\"1.0\"?>\n<speak version=\"1.0\" xml:lang=\"zh-CN\">A &nbsp; B\n<p>段落</speak>")
+	if err != nil {
+		t.Fatalf("恢复模式解析失败: %v", err)
+	}
+	if paragraph, ok := result.Root.Content[1].(*Paragraph); !ok || paragraph.Start.String() != "3:1" || paragraph.Content[0].(Text).Start.String() != "3:4" {
+		t.Errorf("p 位置应对应原始输入: %+v", result.Root.Content)
+	}
+
+	// 没有引号的属性值加上引号，警告指向原文中的值
+	result, err = NewParser(config).Parse(`<speak version="1.0" xml:lang="zh-CN">A<break time=1s/><audio src = a/b.wav>B</audio></speak>`)
+	if err != nil {
+		t.Fatalf("恢复模式解析失败: %v", err)
+	}
+	if br, ok := result.Root.Content[1].(*Break); !ok || br.Time != "1s" {
+		t.Errorf("break 属性值错误: %+v", result.Root.Content)
+	}
+	if audio, ok := result.Root.Content[2].(*Audio); !ok || audio.Src != "a/b.wav" {
+		t.Errorf("audio 属性值错误: %+v", result.Root.Content)
+	}
+	if len(result.Warnings) != 2 || result.Warnings[0].Code != CodeUnquotedAttribute ||
+		result.Warnings[0].Span.Start.String() != "1:52" || result.Warnings[0].Span.End.String() != "1:54" ||
+		result.Warnings[1].Span.Start.String() != "1:69" || result.Warnings[1].Span.End.String() != "1:76" {
+		t.Errorf("引号修复记录错误:\n%s", result.FormatDiagnostics())
+	}
+
+	// 格式正确的文档不产生修复记录
+	result, err = NewParser(config).Parse(`<?xml version="1.0"?><speak version="1.0" xml:lang="zh-CN">A &amp; B &#x4e2d;</speak>`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("不应有修复记录:\n%s", result.FormatDiagnostics())
+	}
+
+	// 输入在标记中途结束时不应 panic
+	for _, input := range []string{"a <", `<speak version="1.0" xml:lang="zh-CN">a</speak><`, "a <![CDATA[", `<speak version="1.0" xml:lang="zh-CN">a <![CDATA[`} {
+		result, err = NewParser(config).Parse(input)
+		if err != nil || result.Root == nil {
+			t.Errorf("%q 应修复成功: %v\n%s", input, err, result.FormatDiagnostics())
+		}
+	}
+
+	// speak 之后的内容被丢弃，不会嵌套在新的 speak 中
+	for _, input := range []string{
+		`<speak version="1.0" xml:lang="zh-CN">正文</speak>多余的文本`,
+		`<speak version="1.0" xml:lang="zh-CN">正文</speak> <!-- 注释 --> <p>多余</p>`,
+		`<speak version="1.0" xml:lang="zh-CN">正文</speak><speak>第二个</speak>`,
+	} {
+		result, err = NewParser(config).Parse(input)
+		if err != nil {
+			t.Fatalf("恢复模式解析失败: %v", err)
+		}
+		counts := result.CountByCode()
+		if counts[CodeTrailingContent] != 1 || counts[CodeWrappedRoot] != 0 || result.Root.Lang != "zh-CN" {
+			t.Errorf("%q 应丢弃 speak 之后的内容:\n%s", input, result.FormatDiagnostics())
+		}
+		if text := plainText(t, result.Root); text != "正文" {
+			t.Errorf("%q 的纯文本错误: %q", input, text)
+		}
+	}
+}
+
+// TestParseFragment 测试解析不带 speak 根元素的片段并拼接到文档中
+func TestParseFragment(t *testing.T) {
+	parser := NewParser(nil)
+
+	fragment, err := parser.ParseFragment(`<prosody rate="slow">慢一点</prosody><break time="1s"/>好的`)
+	if err != nil {
+		t.Fatalf("片段解析失败: %v", err)
+	}
+	if len(fragment.Content) != 3 || len(fragment.Warnings) != 0 {
+		t.Fatalf("片段内容错误: %+v\n%s", fragment.Content, fragment.FormatDiagnostics())
+	}
+	if prosody, ok := fragment.Content[0].(*Prosody); !ok || prosody.Rate != "slow" || prosody.Start.String() != "1:1" {
+		t.Errorf("prosody 解析错误: %+v", fragment.Content[0])
+	}
+	if breakElem, ok := fragment.Content[1].(*Break); !ok || breakElem.Time != "1s" {
+		t.Errorf("break 解析错误: %+v", fragment.Content[1])
+	}
+
+	// 拼接到已有文档
+	result, err := parser.Parse(`<speak version="1.0" xml:lang="zh-CN">开始<p>结束</p></speak>`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	InsertContent(result.Root, 1, fragment.Content)
+	serialized, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	if !strings.Contains(serialized, `开始<prosody rate="slow">慢一点</prosody><break time="1s"/>好的<p>结束</p>`) {
+		t.Errorf("拼接结果错误: %s", serialized)
+	}
+
+	// 拼接到构建器
+	built, err := NewBuilder().Version("1.0").Paragraph(func(eb *ElementBuilder) {
+		eb.Text("前").Fragment(fragment.Content)
+	}).BuildString(false)
+	if err != nil {
+		t.Fatalf("构建失败: %v", err)
+	}
+	if !strings.Contains(built, `<p>前<prosody rate="slow">慢一点</prosody><break time="1s"/>好的</p>`) {
+		t.Errorf("构建结果错误: %s", built)
+	}
+
+	// 插入的是副本，修改片段不影响已拼接的文档
+	fragment.Content[0].(*Prosody).Rate = "fast"
+	fragment.Content[0].(*Prosody).Content[0] = Text{Content: "改了"}
+	if inserted := result.Root.Content[1].(*Prosody); inserted == fragment.Content[0] || inserted.Rate != "slow" || inserted.Content[0].(Text).Content != "慢一点" {
+		t.Errorf("拼接的节点应与片段无关: %+v", inserted)
+	}
+
+	// 同样的嵌套深度验证
+	deep := NewParser(&ValidationConfig{AllowUnknownElements: true, MaxNestingDepth: 1})
+	deepResult, err := deep.ParseFragment(`<p><s>太深</s></p>`)
+	if err == nil || len(deepResult.DiagnosticsByCode(CodeMaxNestingDepth)) != 1 {
+		t.Errorf("应报告嵌套过深: %v", err)
+	}
+
+	if _, err := parser.ParseFragment(`<prosody>未关闭`); err == nil {
+		t.Error("格式错误的片段应返回错误")
+	}
+}
+
+// failingReader 读取时总是返回错误
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, fmt.Errorf("不应读取到这里") }
+
+// TestParseStream 测试流式解析事件和继承的音频属性
+func TestParseStream(t *testing.T) {
+	ssmlContent := `<speak version="1.0" xml:lang="zh-CN"><voice name="xiaoxiao"><prosody rate="slow">第一章<break time="1s"/></prosody>` +
+		`<metadata><dc:title xmlns:dc="urn:dc">书</dc:title></metadata></voice><p>第二章</p></speak>`
+
+	var events []string
+	var props []*AudioProperties
+	handler := EventHandlerFunc(func(event Event) error {
+		name := event.Name.Local
+		if event.Type == EventText {
+			name = event.Text
+		}
+		events = append(events, fmt.Sprintf("%s:%s:%d", event.Type, name, event.Depth))
+		props = append(props, event.Properties)
+		return nil
+	})
+
+	if err := NewParser(nil).Stream(strings.NewReader(ssmlContent), nil, handler); err != nil {
+		t.Fatalf("流式解析失败: %v", err)
+	}
+
+	want := []string{
+		"start:speak:0", "start:voice:1", "start:prosody:2", "text:第一章:3", "start:break:3", "end:break:3", "end:prosody:2",
+		"start:metadata:2", "end:metadata:2", "end:voice:1", "start:p:1", "text:第二章:2", "end:p:1", "end:speak:0",
+	}
+	if strings.Join(events, " ") != strings.Join(want, " ") {
+		t.Errorf("事件序列错误:\n期望 %v\n得到 %v", want, events)
+	}
+	if p := props[3]; p.Voice != "xiaoxiao" || p.Rate != "slow" {
+		t.Errorf("第一章的属性错误: %+v", p)
+	}
+	if p := props[11]; p.Voice != "default" || p.Rate != "medium" {
+		t.Errorf("第二章的属性错误: %+v", p)
+	}
+
+	// 处理完第一章即可停止，后续输入不会被读取
+	stop := fmt.Errorf("stop")
+	reader := io.MultiReader(strings.NewReader(`<speak version="1.0" xml:lang="zh-CN"><p>第一章</p>`), failingReader{})
+	var first string
+	err := NewParser(nil).Stream(reader, nil, EventHandlerFunc(func(event Event) error {
+		if event.Type == EventText {
+			first = event.Text
+			return stop
+		}
+		return nil
+	}))
+	if err != stop || first != "第一章" {
+		t.Errorf("应在第一段文本后停止: %v %q", err, first)
+	}
+
+	deep := NewParser(&ValidationConfig{AllowUnknownElements: true, MaxNestingDepth: 1})
+	if err := deep.Stream(strings.NewReader(`<speak><p><s>太深</s></p></speak>`), nil, EventHandlerFunc(func(Event) error { return nil })); err == nil {
+		t.Error("应报告嵌套过深")
+	}
+}
+
+// TestParseLimits 测试资源限制
+func TestParseLimits(t *testing.T) {
+	limited := func() *ValidationConfig {
+		config := DefaultValidationConfig()
+		config.MaxInputBytes = 4096
+		config.MaxElements = 50
+		config.MaxTextLength = 100
+		config.MaxAttributes = 4
+		config.MaxBreakDuration = 10 * time.Second
+		return config
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		err   error
+		code  DiagnosticCode
+	}{
+		{"输入过大", `<speak version="1.0" xml:lang="zh-CN"><!--` + strings.Repeat("长", 2000) + `--></speak>`, ErrInputTooLarge, CodeMaxInputBytes},
+		{"元素过多", `<speak version="1.0" xml:lang="zh-CN">` + strings.Repeat(`<break/>`, 60) + `</speak>`, ErrTooManyElements, CodeMaxElements},
+		{"文本过长", `<speak version="1.0" xml:lang="zh-CN"><p>` + strings.Repeat("a", 200) + `</p></speak>`, ErrTextTooLong, CodeMaxTextLength},
+		{"属性过多", `<speak version="1.0" xml:lang="zh-CN"><prosody a="1" b="2" c="3" d="4" e="5">x</prosody></speak>`, ErrTooManyAttributes, CodeMaxAttributes},
+		{"停顿过长", `<speak version="1.0" xml:lang="zh-CN"><break time="99999999999s"/></speak>`, ErrBreakTooLong, CodeMaxBreakDuration},
+		{"嵌套过深", `<speak version="1.0" xml:lang="zh-CN">` + strings.Repeat(`<p>`, 20) + strings.Repeat(`</p>`, 20) + `</speak>`, ErrNestingTooDeep, CodeMaxNestingDepth},
+		{"metadata 中的元素过多", `<speak version="1.0" xml:lang="zh-CN"><metadata>` + strings.Repeat(`<a/>`, 60) + `</metadata></speak>`, ErrTooManyElements, CodeMaxElements},
+		{"metadata 中的文本过长", `<speak version="1.0" xml:lang="zh-CN"><metadata><a>` + strings.Repeat("a", 200) + `</a></metadata></speak>`, ErrTextTooLong, CodeMaxTextLength},
+	}
+
+	for _, tt := range tests {
+		result, err := NewParser(limited()).Parse(tt.input)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: 期望错误 %v，得到 %v", tt.name, tt.err, err)
+			continue
+		}
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Code != tt.code {
+			t.Errorf("%s: 错误代码错误: %v", tt.name, err)
+		}
+		if diags := result.DiagnosticsByCode(tt.code); len(diags) != 1 {
+			t.Errorf("%s: 应报告 %s: %s", tt.name, tt.code, result.FormatDiagnostics())
+		} else if limitErr != nil && limitErr.Pos.IsValid() && diags[0].Span.Start != limitErr.Pos {
+			t.Errorf("%s: 诊断位置应为超出限制的节点 %s: %s", tt.name, limitErr.Pos, diags[0])
+		}
+	}
+
+	// 嵌套元素超出限制时，诊断位于该元素而不是 speak
+	result, err := NewParser(limited()).Parse("<speak version=\"1.0\" xml:lang=\"zh-CN\">\n<p>\n  <prosody a=\"1\" b=\"2\" c=\"3\" d=\"4\" e=\"5\">x</prosody></p></speak>")
+	if diags := result.DiagnosticsByCode(CodeMaxAttributes); !errors.Is(err, ErrTooManyAttributes) || len(diags) != 1 || diags[0].Span.Start.String() != "3:3" {
+		t.Errorf("属性过多应报告在 prosody 处: %v %s", err, result.FormatDiagnostics())
+	}
+
+	// 不超出限制的停顿正常解析
+	if _, err := NewParser(limited()).Parse(`<speak version="1.0" xml:lang="zh-CN"><break time="10s"/></speak>`); err != nil {
+		t.Errorf("10s 的停顿不应超出限制: %v", err)
+	}
+
+	// 极深的嵌套在解析时即被拒绝，不会耗尽递归栈
+	deep := `<speak version="1.0" xml:lang="zh-CN">` + strings.Repeat(`<p>`, 100000) + strings.Repeat(`</p>`, 100000) + `</speak>`
+	if _, err := NewParser(nil).Parse(deep); !errors.Is(err, ErrNestingTooDeep) {
+		t.Errorf("应拒绝极深的嵌套: %v", err)
+	}
+
+	// 片段同样受限制
+	if _, err := NewParser(limited()).ParseFragment(strings.Repeat(`<mark name="m"/>`, 60)); !errors.Is(err, ErrTooManyElements) {
+		t.Errorf("片段应报告元素过多: %v", err)
+	}
+
+	// 流式解析不限制输入大小和元素总数，只检查单个节点
+	events := 0
+	countEvents := EventHandlerFunc(func(Event) error { events++; return nil })
+	long := `<speak version="1.0" xml:lang="zh-CN">` + strings.Repeat(`<s>第一句</s><break/>`, 1000) + `</speak>`
+	if err := NewParser(limited()).Stream(strings.NewReader(long), nil, countEvents); err != nil || events != 5002 {
+		t.Errorf("流式解析不应受整个文档的限制: %v %d", err, events)
+	}
+	err = NewParser(limited()).Stream(strings.NewReader(`<speak version="1.0" xml:lang="zh-CN"><p>`+strings.Repeat("a", 200)+`</p></speak>`), nil, countEvents)
+	if !errors.Is(err, ErrTextTooLong) {
+		t.Errorf("流式解析应报告文本过长: %v", err)
+	}
+
+	// 默认配置不限制资源，已有的调用方不受影响
+	if _, err := NewParser(nil).Parse(`<speak version="1.0" xml:lang="zh-CN"><p>` + strings.Repeat("a", 2<<20) + `</p></speak>`); err != nil {
+		t.Errorf("默认配置不应限制文本长度: %v", err)
+	}
+}
+
+// countdownContext 在 Err 被调用指定次数后变为已取消的上下文
+type countdownContext struct {
+	context.Context
+	remaining int
+}
+
+func (c *countdownContext) Err() error {
+	if c.remaining <= 0 {
+		return context.Canceled
+	}
+	c.remaining--
+	return nil
+}
+
+// TestContextCancellation 测试带 context 的解析和处理
+func TestContextCancellation(t *testing.T) {
+	input := `<speak version="1.0" xml:lang="zh-CN"><p>第一段</p><p>第二段</p><p>第三段</p></speak>`
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := NewParser(nil).ParseContext(cancelled, input)
+	if err != context.Canceled {
+		t.Fatalf("已取消的 context 应返回 context.Canceled: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("取消不应记录诊断信息: %s", result.FormatDiagnostics())
+	}
+
+	// 在嵌套的元素中取消，同样直接返回 ctx.Err()
+	result, err = NewParser(nil).ParseContext(&countdownContext{Context: context.Background(), remaining: 2}, input)
+	if err != context.Canceled || len(result.Errors) != 0 {
+		t.Errorf("解析应在第一段中停止: %v %s", err, result.FormatDiagnostics())
+	}
+
+	config := DefaultValidationConfig()
+	config.Recover = true
+	if _, err := NewParser(config).ParseContext(cancelled, input); err != context.Canceled {
+		t.Errorf("恢复模式读取输入时应检查 context: %v", err)
+	}
+	if _, err := NewParser(nil).ParseFragmentContext(cancelled, `<break/>`); err != context.Canceled {
+		t.Errorf("片段解析应检查 context: %v", err)
+	}
+	err = NewParser(nil).StreamContext(cancelled, strings.NewReader(input), nil, EventHandlerFunc(func(Event) error { return nil }))
+	if err != context.Canceled {
+		t.Errorf("流式解析应检查 context: %v", err)
+	}
+	scanner := NewParser(nil).NewJSONLScanner(strings.NewReader(`{"ssml": "<speak/>"}`), "")
+	scanner.SetContext(cancelled)
+	if scanner.Scan() || scanner.Err() != context.Canceled {
+		t.Errorf("扫描应检查 context: %v", scanner.Err())
+	}
+
+	parsed, err := NewParser(nil).Parse(input)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	// 处理完第一段后取消
+	_, err = NewAudioProcessor().ProcessSSMLContext(&countdownContext{Context: context.Background(), remaining: 2}, parsed.Root)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "1:55: processing cancelled after 1 segments") {
+		t.Errorf("处理应在第一段之后停止: %v", err)
+	}
+
+	processor := NewCompleteSSMLToAudioProcessor(NewMockTTSAdapter(16000), 16000)
+	if _, _, err := processor.ProcessSSMLToAudioContext(cancelled, input); !errors.Is(err, context.Canceled) {
+		t.Errorf("完整处理应检查 context: %v", err)
+	}
+	if _, _, err := processor.ProcessSSMLToAudioContext(context.Background(), input); err != nil {
+		t.Errorf("未取消时应正常处理: %v", err)
+	}
+}
+
+// plainText 返回文档的纯文本
+func plainText(t *testing.T, speak *Speak) string {
+	result, err := NewAudioProcessor().ProcessSSML(speak)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	return result.PlainText
+}
+
+// TestDocumentScanner 测试读取多个文档
+func TestDocumentScanner(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<speak version="1.0" xml:lang="zh-CN">第一篇</speak>
+<?xml version="1.0"?>
+<speak xml:lang="en-US"><p>second</p></speak>
+<speak version="1.0" xml:lang="zh-CN"><voice name="xiaoxiao">第三篇</voice></speak>
+`
+	scanner := NewParser(nil).NewDocumentScanner(strings.NewReader(input))
+	var texts []string
+	var lines []int
+	for scanner.Scan() {
+		result := scanner.Result()
+		texts = append(texts, plainText(t, result.Root))
+		lines = append(lines, scanner.Line())
+		if scanner.Index() == 1 && len(result.DiagnosticsByCode(CodeMissingVersion)) != 1 {
+			t.Errorf("第二篇应单独报告缺少 version: %s", result.FormatDiagnostics())
+		}
+		if scanner.Index() != 1 && len(result.Warnings) != 0 {
+			t.Errorf("第 %d 篇不应有警告: %s", scanner.Index(), result.FormatDiagnostics())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	if strings.Join(texts, "|") != "第一篇|second|第三篇" {
+		t.Errorf("文档内容错误: %v", texts)
+	}
+	if fmt.Sprint(lines) != "[2 4 5]" {
+		t.Errorf("文档行号错误: %v", lines)
+	}
+
+	// Parse 只返回第一个文档并给出警告
+	result, err := NewParser(nil).Parse(input)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if plainText(t, result.Root) != "第一篇" || len(result.DiagnosticsByCode(CodeMultipleRoots)) != 2 {
+		t.Errorf("Parse 应返回第一个文档: %q %s", plainText(t, result.Root), result.FormatDiagnostics())
+	}
+
+	// 命名空间声明只在声明它的文档中有效
+	second := `<speak version="1.0" xml:lang="zh-CN"><metadata><mstts:info/></metadata><mstts:silence type="Leading" value="1s"/>二</speak>`
+	alone, err := NewParser(nil).Parse(second)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	expected, _ := NewSerializer(false).Serialize(alone.Root)
+	scanner = NewParser(nil).NewDocumentScanner(strings.NewReader(`<speak version="1.0" xml:lang="zh-CN" xmlns:mstts="https://www.w3.org/2001/mstts"><mstts:silence type="Leading" value="1s"/>一</speak>` + second))
+	for scanner.Scan() {
+		if scanner.Index() != 1 {
+			continue
+		}
+		if len(scanner.decoder.namespaces) != 0 {
+			t.Errorf("第二篇不应继承第一篇的命名空间声明: %v", scanner.decoder.namespaces)
+		}
+		root := scanner.Result().Root
+		if metadata, ok := root.Content[0].(*Metadata); !ok || metadata.InnerXML != `<mstts:info/>` {
+			t.Errorf("第二篇的 metadata 错误: %#v", root.Content[0])
+		}
+		if serialized, _ := NewSerializer(false).Serialize(root); serialized != expected {
+			t.Errorf("第二篇应与单独解析的结果相同:\n%s\n%s", serialized, expected)
+		}
+	}
+	if err := scanner.Err(); err != nil || scanner.Index() != 1 {
+		t.Fatalf("扫描失败: %d %v", scanner.Index(), err)
+	}
+
+	// 文档中的语法错误使扫描停止，之前的文档不受影响
+	scanner = NewParser(nil).NewDocumentScanner(strings.NewReader(`<speak>一</speak><speak><p>二</speak>`))
+	count := 0
+	for scanner.Scan() {
+		count++
+	}
+	if count != 1 || scanner.Err() == nil || len(scanner.Result().DiagnosticsByCode(CodeXMLSyntax)) != 1 {
+		t.Errorf("语法错误应停止扫描: %d %v", count, scanner.Err())
+	}
+
+	// 提前结束时 Close 释放解码器，已返回的结果仍然可用，可以多次调用
+	scanner = NewParser(nil).NewDocumentScanner(strings.NewReader(`<speak>一</speak><speak>二</speak>`))
+	if !scanner.Scan() {
+		t.Fatalf("扫描失败: %v", scanner.Err())
+	}
+	first := scanner.Result()
+	if err := scanner.Close(); err != nil || scanner.decoder != nil {
+		t.Errorf("Close 应释放解码器: %v", err)
+	}
+	if err := scanner.Close(); err != nil || scanner.Scan() || scanner.Err() != nil {
+		t.Errorf("Close 之后应停止扫描: %v", scanner.Err())
+	}
+	if text := first.Root.Content[0].(Text); text.Content != "一" {
+		t.Errorf("Close 之后结果应仍然可用: %+v", first.Root.Content)
+	}
+
+	jsonl := `{"id": 1, "ssml": "<speak version=\"1.0\" xml:lang=\"zh-CN\">你好</speak>"}
+
+{"id": 2, "text": "缺少字段"}
+not json
+{"id": 4, "ssml": "<speak version=\"1.0\" xml:lang=\"zh-CN\"><p>再见"}
+{"id": 5, "ssml": "<speak version=\"1.0\" xml:lang=\"zh-CN\">结束</speak>"}`
+	scanner = NewParser(nil).NewJSONLScanner(strings.NewReader(jsonl), "")
+	var summary []string
+	for scanner.Scan() {
+		var record struct{ ID int }
+		_ = json.Unmarshal(scanner.Record(), &record)
+		result := scanner.Result()
+		switch {
+		case len(result.DiagnosticsByCode(CodeInvalidRecord)) > 0:
+			summary = append(summary, fmt.Sprintf("%d@%d:invalid", record.ID, scanner.Line()))
+		case result.HasErrors():
+			summary = append(summary, fmt.Sprintf("%d@%d:%s", record.ID, scanner.Line(), result.Errors[0].Span.Start))
+		default:
+			summary = append(summary, fmt.Sprintf("%d@%d:%s", record.ID, scanner.Line(), plainText(t, result.Root)))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	want := "1@1:你好 2@3:invalid 0@4:invalid 4@5:1:48 5@6:结束"
+	if strings.Join(summary, " ") != want {
+		t.Errorf("JSONL 扫描结果错误:\n期望 %s\n得到 %s", want, strings.Join(summary, " "))
+	}
+
+	// 过长的行在读入内存之前被拒绝
+	config := DefaultValidationConfig()
+	config.MaxInputBytes = 100
+	huge := `{"ssml": "<speak>短</speak>"}` + "\n" + `{"ssml": "` + strings.Repeat("长", 100000)
+	scanner = NewParser(config).NewJSONLScanner(strings.NewReader(huge), "")
+	count = 0
+	for scanner.Scan() {
+		count++
+	}
+	if err := scanner.Err(); count != 1 || !errors.Is(err, ErrInputTooLarge) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("过长的行应返回 ErrInputTooLarge: %d %v", count, err)
+	}
+	if result := scanner.Result(); result == nil || len(result.DiagnosticsByCode(CodeMaxInputBytes)) != 1 {
+		t.Errorf("过长的行应报告 max-input-bytes: %v", result)
+	}
+}
+
+// TestParseCharsets 测试非 UTF-8 编码的输入和输出
+func TestParseCharsets(t *testing.T) {
+	encode := func(charset, text string) []byte {
+		data, err := encodeOutput(text, charset)
+		if err != nil {
+			t.Fatalf("编码 %s 失败: %v", charset, err)
+		}
+		return data
+	}
+	doc := func(charset, text string) string {
+		return `<?xml version="1.0" encoding="` + charset + `"?><speak version="1.0" xml:lang="zh-CN"><p>` + text + `</p></speak>`
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"GBK", encode("GBK", doc("GBK", "你好，世界")), "你好，世界"},
+		{"GB2312", encode("GB2312", doc("gb2312", "简体中文")), "简体中文"},
+		{"GB18030", encode("GB18030", doc("GB18030", "䶮字𠀀")), "䶮字𠀀"},
+		{"Big5", encode("Big5", doc("Big5", "繁體中文")), "繁體中文"},
+		{"UTF-16LE BOM", append([]byte{0xFF, 0xFE}, encode("UTF-16LE", doc("UTF-16", "你好"))...), "你好"},
+		{"UTF-16BE BOM", encode("UTF-16", doc("UTF-16", "你好")), "你好"},
+		{"UTF-16BE 无 BOM", encode("UTF-16BE", doc("UTF-16", "你好")), "你好"},
+		{"UTF-8 BOM", append([]byte{0xEF, 0xBB, 0xBF}, doc("UTF-8", "你好")...), "你好"},
+	}
+
+	for _, tt := range tests {
+		result, err := NewParser(nil).ParseReader(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: 解析失败: %v", tt.name, err)
+			continue
+		}
+		text := plainText(t, result.Root)
+		if text != tt.want || !utf8.ValidString(text) {
+			t.Errorf("%s: 期望 %q，得到 %q", tt.name, tt.want, text)
+		}
+
+		// 不能直接读取开头字节的 reader 经过 bufio 检测编码
+		result, err = NewParser(nil).ParseReader(struct{ io.Reader }{bytes.NewReader(tt.input)})
+		if err != nil || plainText(t, result.Root) != tt.want {
+			t.Errorf("%s: 普通 reader 的解析结果错误: %v", tt.name, err)
+		}
+	}
+
+	// 恢复模式先转换编码再修复
+	config := DefaultValidationConfig()
+	config.Recover = true
+	result, err := NewParser(config).ParseReader(bytes.NewReader(encode("GBK", doc("GBK", "甲&nbsp;乙 & 丙"))))
+	if err != nil {
+		t.Fatalf("恢复模式解析失败: %v", err)
+	}
+	if text := plainText(t, result.Root); text != "甲 乙 & 丙" {
+		t.Errorf("恢复模式文本错误: %q", text)
+	}
+
+	result, err = NewParser(config).Parse(doc("UTF-8", "a\xffb"))
+	if err != nil || plainText(t, result.Root) != "a\uFFFDb" || len(result.DiagnosticsByCode(CodeInvalidEncoding)) != 1 {
+		t.Errorf("恢复模式应替换无效的 UTF-8: %v", err)
+	}
+
+	if _, err := NewParser(nil).Parse(doc("EBCDIC-XYZ", "你好")); err == nil || !strings.Contains(err.Error(), "unsupported charset") {
+		t.Errorf("应报告不支持的编码: %v", err)
+	}
+
+	// 序列化为 GBK，无法表示的字符写为字符引用
+	speak, err := NewParser(nil).Parse(doc("UTF-8", "你好😀"))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	serializer := NewSerializer(false)
+	serializer.Encoding = "GBK"
+	output, err := serializer.Encode(speak.Root)
+	if err != nil {
+		t.Fatalf("编码输出失败: %v", err)
+	}
+	if !bytes.HasPrefix(output, []byte(`<?xml version="1.0" encoding="GBK"?>`)) || !bytes.Contains(output, []byte("&#128512;")) {
+		t.Errorf("GBK 输出错误: %q", output)
+	}
+	reparsed, err := NewParser(nil).ParseReader(bytes.NewReader(output))
+	if err != nil {
+		t.Fatalf("重新解析 GBK 输出失败: %v", err)
+	}
+	if text := plainText(t, reparsed.Root); text != "你好😀" {
+		t.Errorf("GBK 往返文本错误: %q", text)
+	}
+
+	serializer.Encoding = "latin-x"
+	if _, err := serializer.Encode(speak.Root); err == nil {
+		t.Error("应报告不支持的输出编码")
+	}
+}
+
+// TestValidateAttributes 测试属性值验证
+func TestValidateAttributes(t *testing.T) {
+	valid := `<speak version="1.1" xml:lang="zh-CN">` +
+		`<break time="500ms" strength="x-strong"/><break time="1.5s"/>` +
+		`<emphasis level="reduced">轻</emphasis>` +
+		`<prosody rate="80%" pitch="+2st" range="120Hz" volume="-6dB" duration="2s">慢</prosody>` +
+		`<prosody rate="x-fast" pitch="-10%" range="x-high" volume="+0.5dB">快</prosody>` +
+		`<prosody rate="+10%">快一点</prosody><prosody rate="-20%">慢一点</prosody>` +
+		`<phoneme alphabet="x-sampa" ph="ni3">你</phoneme>` +
+		`<voice gender="female" age="30" variant="2">声音</voice>` +
+		`<audio src="a.wav" clipBegin="1s" soundLevel="+3dB" speed="150%" repeatCount="2"/>` +
+		`</speak>`
+	strict := NewParser(&ValidationConfig{StrictMode: true, MaxNestingDepth: 10})
+	if result, err := strict.Parse(valid); err != nil || len(result.Warnings) != 0 {
+		t.Fatalf("合法的属性不应报告问题: %v %s", err, result.FormatDiagnostics())
+	}
+
+	tests := []struct {
+		element string
+		value   string
+	}{
+		{`<break time="-1s"/>`, "-1s"},
+		{`<break time="10"/>`, "10"},
+		{`<break strength="loud"/>`, "loud"},
+		{`<emphasis level="high">x</emphasis>`, "high"},
+		{`<prosody rate="+-10%">x</prosody>`, "+-10%"},
+		{`<audio src="a.wav" speed="+10%"/>`, "+10%"},
+		{`<audio src="a.wav" speed="-20%"/>`, "-20%"},
+		{`<prosody pitch="120">x</prosody>`, "120"},
+		{`<prosody pitch="+2semitones">x</prosody>`, "+2semitones"},
+		{`<prosody range="wide">x</prosody>`, "wide"},
+		{`<prosody volume="6dB">x</prosody>`, "6dB"},
+		{`<prosody volume="80">x</prosody>`, "80"},
+		{`<phoneme alphabet="sampa" ph="a">x</phoneme>`, "sampa"},
+		{`<voice gender="robot">x</voice>`, "robot"},
+		{`<voice age="young">x</voice>`, "young"},
+		{`<voice variant="0">x</voice>`, "0"},
+		{`<lang xml:lang="en" onlangfailure="skip">x</lang>`, "skip"},
+	}
+
+	for _, tt := range tests {
+		input := `<speak version="1.0" xml:lang="zh-CN">` + tt.element + `</speak>`
+
+		result, err := NewParser(nil).Parse(input)
+		if err != nil {
+			t.Errorf("%s: 非严格模式不应失败: %v", tt.element, err)
+			continue
+		}
+		warnings := result.DiagnosticsByCode(CodeInvalidAttribute)
+		if len(warnings) != 1 || warnings[0].Severity != SeverityWarning || !strings.Contains(warnings[0].Message, fmt.Sprintf("%q", tt.value)) {
+			t.Errorf("%s: 应报告包含取值的警告: %s", tt.element, result.FormatDiagnostics())
+		}
+
+		result, err = strict.Parse(input)
+		if err == nil || !strings.Contains(err.Error(), tt.value) {
+			t.Errorf("%s: 严格模式应报告错误: %v", tt.element, err)
+		}
+		if errs := result.DiagnosticsByCode(CodeInvalidAttribute); len(errs) != 1 || errs[0].Severity != SeverityError || errs[0].Span.Start.Column != 39 {
+			t.Errorf("%s: 严格模式的诊断信息错误: %s", tt.element, result.FormatDiagnostics())
+		}
+	}
+
+	// 缺少必需的属性
+	result, err := NewParser(nil).Parse(`<speak version="1.0" xml:lang="zh-CN"><sub>W3C</sub><say-as>1</say-as></speak>`)
+	if err != nil || len(result.DiagnosticsByCode(CodeInvalidAttribute)) != 2 {
+		t.Errorf("应报告缺少 alias 和 interpret-as: %v %s", err, result.FormatDiagnostics())
+	}
+
+	// 片段同样验证属性
+	fragment, err := NewParser(nil).ParseFragment(`<break strength="huge"/>`)
+	if err != nil || len(fragment.DiagnosticsByCode(CodeInvalidAttribute)) != 1 {
+		t.Errorf("片段应报告无效的属性: %v", err)
+	}
+}
+
+// TestDurationCheck 测试时长检查
+func TestDurationCheck(t *testing.T) {
+	// 每字 150ms，10 个字 1.5s，加上 3s 的停顿共 4.5s
+	input := `<speak version="1.0" xml:lang="zh-CN">一二三四五<break time="3s"/>六七八九十</speak>`
+
+	config := DefaultValidationConfig()
+	result, err := NewParser(config).Parse(input)
+	if err != nil || result.Duration != 0 {
+		t.Fatalf("默认不应估算时长: %v %v", err, result.Duration)
+	}
+
+	config.DurationCheck = DurationCheckWarn
+	config.MaxDuration = 4 * time.Second
+	config.MaxBreakDuration = 2 * time.Second
+	result, err = NewParser(config).Parse(input)
+	if err != nil {
+		t.Fatalf("警告模式不应失败: %v", err)
+	}
+	if result.Duration != 4500*time.Millisecond {
+		t.Errorf("预计时长错误: %v", result.Duration)
+	}
+	if d := result.DiagnosticsByCode(CodeMaxDuration); len(d) != 1 || d[0].Severity != SeverityWarning || !strings.Contains(d[0].Message, "4.5s") {
+		t.Errorf("应警告总时长: %s", result.FormatDiagnostics())
+	}
+	if d := result.DiagnosticsByCode(CodeLongBreak); len(d) != 1 || d[0].Span.Start.Column != 54 {
+		t.Errorf("应警告过长的停顿: %s", result.FormatDiagnostics())
+	}
+
+	// 拒绝模式下超过 MaxBreakDuration 的 time 在解析时即失败
+	config.DurationCheck = DurationCheckReject
+	result, err = NewParser(config).Parse(input)
+	if !errors.Is(err, ErrBreakTooLong) || len(result.DiagnosticsByCode(CodeMaxBreakDuration)) != 1 {
+		t.Errorf("拒绝模式应返回 ErrBreakTooLong: %v %s", err, result.FormatDiagnostics())
+	}
+
+	// strength 也按默认时长检查
+	config.MaxBreakDuration = time.Second
+	result, err = NewParser(config).Parse(`<speak version="1.0" xml:lang="zh-CN">好<break strength="x-strong"/></speak>`)
+	if !errors.Is(err, ErrDurationTooLong) || len(result.DiagnosticsByCode(CodeLongBreak)) != 1 {
+		t.Errorf("x-strong 的停顿应超出 1s: %v %s", err, result.FormatDiagnostics())
+	}
+
+	config.MaxBreakDuration = 0
+	result, err = NewParser(config).Parse(input)
+	if !errors.Is(err, ErrDurationTooLong) || len(result.Errors) != 1 {
+		t.Errorf("拒绝模式应返回 ErrDurationTooLong: %v %s", err, result.FormatDiagnostics())
+	}
+
+	// 无法估算时报告错误，不能当作没有超出
+	estimator := NewAudioProcessor()
+	estimator.SetLexiconRegistry(NewLexiconRegistry(MemoryLexiconLoader{}))
+	parser := NewParser(config)
+	parser.SetAudioProcessor(estimator)
+	lexicon := `<speak version="1.1" xml:lang="zh-CN"><lexicon uri="missing.pls" xml:id="m"/>好</speak>`
+	if result, err = parser.Parse(lexicon); err == nil || len(result.DiagnosticsByCode(CodeMaxDuration)) != 1 {
+		t.Errorf("拒绝模式下无法估算应返回错误: %v %s", err, result.FormatDiagnostics())
+	}
+	warn := *config
+	warn.DurationCheck = DurationCheckWarn
+	parser = NewParser(&warn)
+	parser.SetAudioProcessor(estimator)
+	if result, err = parser.Parse(lexicon); err != nil || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0].Message, "Cannot estimate") {
+		t.Errorf("警告模式下无法估算应报告警告: %v %s", err, result.FormatDiagnostics())
+	}
+
+	// 估算使用设置的音频处理器，慢速语音的时长更长
+	config.MaxDuration = 2 * time.Second
+	parser = NewParser(config)
+	result, err = parser.Parse(`<speak version="1.0" xml:lang="zh-CN"><prosody rate="x-slow">一二三四五六七</prosody></speak>`)
+	if !errors.Is(err, ErrDurationTooLong) || result.Duration != 2100*time.Millisecond {
+		t.Errorf("慢速语音应超出 2s: %v %v", err, result.Duration)
+	}
+	processor := NewAudioProcessor()
+	processor.charToTimeRatio = 100 * time.Millisecond
+	parser.SetAudioProcessor(processor)
+	if result, err = parser.Parse(`<speak version="1.0" xml:lang="zh-CN"><prosody rate="x-slow">一二三四五六七</prosody></speak>`); err != nil || result.Duration != 1400*time.Millisecond {
+		t.Errorf("应使用设置的音频处理器估算: %v %v", err, result.Duration)
+	}
+
+	// 估算使用解析的 ctx，加载词典时取消后停止，警告模式下同样返回错误
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	estimator = NewAudioProcessor()
+	estimator.SetLexiconRegistry(NewLexiconRegistry(LexiconLoaderFunc(func(uri string) (io.ReadCloser, error) {
+		cancel()
+		return io.NopCloser(strings.NewReader(`<lexicon version="1.0" xmlns="http://www.w3.org/2005/01/pronunciation-lexicon" alphabet="ipa" xml:lang="zh-CN"/>`)), nil
+	})))
+	parser = NewParser(&warn)
+	parser.SetAudioProcessor(estimator)
+	result, err = parser.ParseContext(ctx, `<speak version="1.1" xml:lang="zh-CN"><lexicon uri="a.pls" xml:id="a"/><lexicon uri="b.pls" xml:id="b"/>好</speak>`)
+	if err != context.Canceled || len(result.Errors) != 0 {
+		t.Errorf("取消后时长估算应直接返回 ctx.Err(): %v %s", err, result.FormatDiagnostics())
+	}
+}
+
+func TestContentModel(t *testing.T) {
+	input := `<speak version="1.0" xml:lang="zh-CN"><s>第一句<p>段落</p>第二句</s><say-as interpret-as="characters">A<break/>B</say-as><lexicon uri="a.pls" xml:id="a"/></speak>`
+
+	config := DefaultValidationConfig()
+	result, err := NewParser(config).Parse(input)
+	if err != nil {
+		t.Fatalf("非严格模式不应失败: %v", err)
+	}
+	nesting := result.DiagnosticsByCode(CodeInvalidNesting)
+	if len(nesting) != 2 || nesting[0].Severity != SeverityWarning || nesting[0].Span.Start.Column != 51 {
+		t.Errorf("应警告 s 中的 p 和 say-as 中的 break: %s", result.FormatDiagnostics())
+	}
+	if len(result.DiagnosticsByCode(CodeMisplacedContent)) != 1 {
+		t.Errorf("应警告不在开头的 lexicon: %s", result.FormatDiagnostics())
+	}
+	if _, ok := result.Root.Content[0].(*Sentence); !ok {
+		t.Errorf("默认不应调整结构: %#v", result.Root.Content[0])
+	}
+
+	// 通过 voice 间接嵌套也不允许
+	result, _ = NewParser(config).Parse(`<speak version="1.0" xml:lang="zh-CN"><p><voice name="a"><p>嵌套</p></voice></p></speak>`)
+	if d := result.DiagnosticsByCode(CodeInvalidNesting); len(d) != 1 || !strings.Contains(d[0].Message, "within a paragraph") {
+		t.Errorf("应警告 p 中间接嵌套的 p: %s", result.FormatDiagnostics())
+	}
+
+	// 空元素中的内容被丢弃并报告
+	result, _ = NewParser(config).Parse(`<speak version="1.0" xml:lang="zh-CN">开始<break time="1s">丢弃</break>结束</speak>`)
+	if d := result.DiagnosticsByCode(CodeMisplacedContent); len(d) != 1 || !strings.Contains(d[0].Message, "<break> must be empty") {
+		t.Errorf("应报告 break 中的内容: %s", result.FormatDiagnostics())
+	}
+	if text := plainText(t, result.Root); text != "开始结束" {
+		t.Errorf("break 中的内容应被丢弃: %q", text)
+	}
+	result, _ = NewParser(config).Parse(`<speak version="1.0" xml:lang="zh-CN" xmlns:mstts="https://www.w3.org/2001/mstts">开始<mstts:silence type="Leading" value="200ms">丢弃</mstts:silence>结束</speak>`)
+	if d := result.DiagnosticsByCode(CodeMisplacedContent); len(d) != 1 || !strings.Contains(d[0].Message, "<mstts:silence> must be empty") {
+		t.Errorf("应报告 mstts:silence 中的内容: %s", result.FormatDiagnostics())
+	}
+
+	// 严格模式下为错误
+	config.StrictMode = true
+	result, err = NewParser(config).Parse(input)
+	if err == nil || !strings.Contains(err.Error(), "1:51") || len(result.DiagnosticsByCode(CodeInvalidNesting)) != 2 {
+		t.Errorf("严格模式应返回嵌套错误: %v %s", err, result.FormatDiagnostics())
+	}
+
+	// 自动调整：拆分 s，break 替换为文本，lexicon 移到开头
+	config.StrictMode = false
+	config.Restructure = true
+	result, err = NewParser(config).Parse(input)
+	if err != nil {
+		t.Fatalf("调整结构不应失败: %v", err)
+	}
+	content := result.Root.Content
+	if len(content) != 5 {
+		t.Fatalf("调整后应有 5 个节点: %#v", content)
+	}
+	if _, ok := content[0].(*Lexicon); !ok {
+		t.Errorf("lexicon 应移到开头: %#v", content[0])
+	}
+	first, ok1 := content[1].(*Sentence)
+	paragraph, ok2 := content[2].(*Paragraph)
+	second, ok3 := content[3].(*Sentence)
+	if !ok1 || !ok2 || !ok3 || first == second {
+		t.Fatalf("s 应在 p 前后拆分: %#v", content)
+	}
+	if first.Content[0].(Text).Content != "第一句" || paragraph.Content[0].(Text).Content != "段落" || second.Content[0].(Text).Content != "第二句" {
+		t.Errorf("拆分后的内容错误: %#v", content)
+	}
+	sayAs := content[4].(*SayAs)
+	if len(sayAs.Content) != 2 {
+		t.Errorf("say-as 中的 break 应被去掉: %#v", sayAs.Content)
+	}
+	output, err := NewSerializer(false).Serialize(result.Root)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	rerun, err := NewParser(DefaultValidationConfig()).Parse(output)
+	if err != nil || len(rerun.DiagnosticsByCode(CodeInvalidNesting)) != 0 || len(rerun.DiagnosticsByCode(CodeMisplacedContent)) != 0 {
+		t.Errorf("调整后的文档应符合内容模型: %v %s", err, rerun.FormatDiagnostics())
+	}
+
+	// 移出 voice、prosody 的 p 保留外层元素的属性
+	result, err = NewParser(config).Parse(`<speak version="1.0" xml:lang="zh-CN"><s>前面<voice name="a"><p>里面</p></voice>后面</s><s><prosody rate="slow"><emphasis><p><s>慢</s></p></emphasis></prosody></s></speak>`)
+	if err != nil || len(result.Root.Content) != 4 {
+		t.Fatalf("调整结构失败: %v %#v", err, result.Root.Content)
+	}
+	paragraph, ok := result.Root.Content[1].(*Paragraph)
+	if !ok || len(paragraph.Content) != 1 {
+		t.Fatalf("p 应移到 s 之外: %#v", result.Root.Content[1])
+	}
+	if voice, ok := paragraph.Content[0].(*Voice); !ok || voice.Name != "a" || voice.Content[0].(Text).Content != "里面" {
+		t.Errorf("p 中应重建 voice: %#v", paragraph.Content[0])
+	}
+	processed, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	slow := false
+	for _, segment := range processed.Segments {
+		if segment.Text == "慢" {
+			slow = segment.Properties.Rate == "slow"
+		}
+	}
+	if !slow {
+		t.Errorf("移出 prosody 的文本应保留语速: %+v", processed.Segments)
+	}
+	output, _ = NewSerializer(false).Serialize(result.Root)
+	if !strings.HasSuffix(output, `<s><prosody rate="slow"><emphasis>慢</emphasis></prosody></s></speak>`) {
+		t.Errorf("prosody 和 emphasis 应在 s 内部重建: %s", output)
+	}
+
+	// w 中不允许的元素被去掉，保留文本，不拆分 w
+	result, err = NewParser(config).Parse(`<speak version="1.0" xml:lang="zh-CN"><w role="noun">ab<voice name="a">cd</voice></w></speak>`)
+	if err != nil || len(result.Root.Content) != 1 {
+		t.Fatalf("w 不应被拆分: %v %#v", err, result.Root.Content)
+	}
+	if w := result.Root.Content[0].(*W); w.Role != "noun" || len(w.Content) != 2 || w.Content[1].(Text).Content != "cd" {
+		t.Errorf("w 中的 voice 应替换为其文本: %#v", w.Content)
+	}
+
+	// 片段只检查元素内部的嵌套
+	fragment, err := NewParser(DefaultValidationConfig()).ParseFragment(`<p>段落</p><emphasis><p>强调</p></emphasis>`)
+	if err != nil || len(fragment.DiagnosticsByCode(CodeInvalidNesting)) != 1 {
+		t.Errorf("片段应只报告 emphasis 中的 p: %v %s", err, fragment.FormatDiagnostics())
+	}
+}
+
+func TestLanguageTags(t *testing.T) {
+	normalized := map[string]string{
+		"zh_cn":       "zh-CN",
+		"EN-us":       "en-US",
+		"cmn-Hans-CN": "zh-CN",
+		"zh-Hant-TW":  "zh-TW",
+		"zh-Hant-CN":  "zh-Hant-CN",
+		"en-Latn-US":  "en-US",
+		"iw":          "he",
+	}
+	for input, want := range normalized {
+		if got, err := NormalizeLanguage(input); err != nil || got != want {
+			t.Errorf("%s 应规范化为 %s: %s %v", input, want, got, err)
+		}
+	}
+	if _, err := NormalizeLanguage("en--US"); err == nil {
+		t.Error("格式错误的标签应返回错误")
+	}
+
+	supported := []string{"zh-CN", "en", "zh-Hant"}
+	matches := map[string]string{"cmn-Hans-CN": "zh-CN", "en-GB": "en", "zh-Hant-HK": "zh-Hant"}
+	for input, want := range matches {
+		if got, ok := MatchLanguage(input, supported); !ok || got != want {
+			t.Errorf("%s 应匹配 %s: %s", input, want, got)
+		}
+	}
+	if _, ok := MatchLanguage("fr-FR", supported); ok {
+		t.Error("fr-FR 不应匹配")
+	}
+
+	input := `<speak version="1.1" xml:lang="zh_cn">你好<lang xml:lang="en--US">world</lang><voice xml:lang="cmn-Hans-CN">再见</voice></speak>`
+	result, err := NewParser(nil).Parse(input)
+	if err != nil {
+		t.Fatalf("非严格模式不应失败: %v", err)
+	}
+	d := result.DiagnosticsByCode(CodeInvalidLanguage)
+	if len(d) != 2 || d[0].Fix != `use "zh-CN"` || !strings.Contains(d[1].Message, "en--US") {
+		t.Errorf("应警告 zh_cn 和 en--US: %s", result.FormatDiagnostics())
+	}
+
+	config := DefaultValidationConfig()
+	config.StrictMode = true
+	if _, err := NewParser(config).Parse(input); err == nil || !strings.Contains(err.Error(), "zh_cn") {
+		t.Errorf("严格模式应返回错误: %v", err)
+	}
+
+	// 音频处理使用规范化的语言
+	audio, err := NewAudioProcessor().ProcessSSML(result.Root)
+	if err != nil {
+		t.Fatalf("音频处理失败: %v", err)
+	}
+	if got := audio.Segments[0].Properties.Language; got != "zh-CN" {
+		t.Errorf("speak 的语言应规范化: %s", got)
+	}
+	if got := audio.Segments[2].Properties.Language; got != "zh-CN" {
+		t.Errorf("voice 的语言应规范化: %s", got)
+	}
+	result, _ = NewParser(nil).Parse(`<speak version="1.1" xml:lang="en-US">hello</speak>`)
+	audio, _ = NewAudioProcessor().ProcessSSML(result.Root)
+	if got := audio.Segments[0].Properties.Language; got != "en-US" {
+		t.Errorf("应使用文档的语言而不是默认语言: %s", got)
+	}
+}
+
+func TestValidateForVendor(t *testing.T) {
+	input := `<speak version="1.1" xml:lang="en-US"><voice name="Joanna">Hello<break time="15s"/>` +
+		`<phoneme alphabet="x-sampa" ph="t@meItoU">tomato</phoneme><amazon:effect name="whispered">quiet</amazon:effect></voice></speak>`
+	result, err := NewParser(nil).Parse(input)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	speak := result.Root
+
+	polly := speak.ValidateFor(PollyProfile, nil)
+	if d := polly.DiagnosticsByCode(CodeUnsupportedElement); len(d) != 1 || !strings.Contains(d[0].Message, "Polly: <voice>") {
+		t.Errorf("Polly 应报告 voice: %s", polly.FormatDiagnostics())
+	}
+	if d := polly.DiagnosticsByCode(CodeVendorLimit); len(d) != 1 || d[0].Span.Start.Column != 65 {
+		t.Errorf("Polly 应报告超过 10s 的停顿: %s", polly.FormatDiagnostics())
+	}
+	if len(polly.DiagnosticsByCode(CodeUnsupportedValue)) != 0 {
+		t.Errorf("Polly 支持 x-sampa: %s", polly.FormatDiagnostics())
+	}
+
+	google := speak.ValidateFor(GoogleProfile, nil)
+	if d := google.DiagnosticsByCode(CodeUnsupportedValue); len(d) != 1 || !strings.Contains(d[0].Message, `alphabet="x-sampa"`) {
+		t.Errorf("Google 应报告 x-sampa: %s", google.FormatDiagnostics())
+	}
+	if d := google.DiagnosticsByCode(CodeUnsupportedElement); len(d) != 1 || !strings.Contains(d[0].Message, "amazon:effect") {
+		t.Errorf("Google 应报告 amazon:effect: %s", google.FormatDiagnostics())
+	}
+	if !google.HasErrors() || google.Root != speak {
+		t.Error("不支持的内容应为错误")
+	}
+
+	azure := speak.ValidateFor(AzureProfile, nil)
+	if len(azure.DiagnosticsByCode(CodeVendorLimit)) != 0 || len(azure.Errors) != 1 {
+		t.Errorf("Azure 只应报告 amazon:effect: %s", azure.FormatDiagnostics())
+	}
+
+	// Azure 的扩展元素和 bookmark
+	result, err = NewParser(&ValidationConfig{AllowUnknownElements: true, MaxNestingDepth: 10}).Parse(`<speak version="1.0" xml:lang="zh-CN" xmlns:mstts="https://www.w3.org/2001/mstts">` +
+		`<mstts:backgroundaudio src="bg.wav"/><voice name="zh-CN-XiaoxiaoNeural"><mstts:viseme type="redlips_front"/>` +
+		`<mstts:audioduration value="5s"/><mstts:express-as style="cheerful">你好<bookmark mark="m"/></mstts:express-as>` +
+		`<mstts:ttsembedding speakerProfileId="x">文本</mstts:ttsembedding></voice></speak>`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if azure := result.Root.ValidateFor(AzureProfile, nil); len(azure.Errors) != 0 {
+		t.Errorf("Azure 应支持其扩展元素: %s", azure.FormatDiagnostics())
+	}
+
+	// 被抑制的代码不报告
+	suppressed := *PollyProfile
+	suppressed.SuppressCodes = []DiagnosticCode{CodeUnsupportedElement}
+	if polly := speak.ValidateFor(&suppressed, nil); len(polly.DiagnosticsByCode(CodeUnsupportedElement)) != 0 || len(polly.DiagnosticsByCode(CodeVendorLimit)) != 1 {
+		t.Errorf("应忽略被抑制的代码: %s", polly.FormatDiagnostics())
+	}
+
+	// voice 的数量和文本长度
+	var builder strings.Builder
+	builder.WriteString(`<speak version="1.1" xml:lang="zh-CN">`)
+	for i := 0; i < 51; i++ {
+		builder.WriteString(`<voice name="a">你好</voice>`)
+	}
+	builder.WriteString(`</speak>`)
+	result, _ = NewParser(nil).Parse(builder.String())
+	if d := result.Root.ValidateFor(AzureProfile, nil).DiagnosticsByCode(CodeVendorLimit); len(d) != 1 || !strings.Contains(d[0].Message, "more than 50 <voice>") {
+		t.Errorf("Azure 应报告 voice 的数量: %v", d)
+	}
+	if d := result.Root.ValidateFor(AlibabaProfile, nil).DiagnosticsByCode(CodeVendorLimit); len(d) != 0 {
+		t.Errorf("102 个字不应超出阿里云的限制: %v", d)
+	}
+
+	// 自定义配置
+	profile := &VendorProfile{Name: "Local", MaxCharacters: 100}
+	if d := result.Root.ValidateFor(profile, nil).DiagnosticsByCode(CodeVendorLimit); len(d) != 1 || !strings.Contains(d[0].Message, "102 characters") {
+		t.Errorf("应报告文本长度: %v", d)
+	}
+	// 时长按传入的处理器估算，估算失败时报告错误
+	pls := `<lexicon version="1.0" xmlns="http://www.w3.org/2005/01/pronunciation-lexicon" alphabet="ipa" xml:lang="en-US">` +
+		`<lexeme><grapheme>W3C</grapheme><alias>World Wide Web Consortium</alias></lexeme></lexicon>`
+	result, _ = NewParser(nil).Parse(`<speak version="1.1" xml:lang="en-US"><lexicon uri="w3c.pls" xml:id="w"/><lookup ref="w">W3C</lookup></speak>`)
+	profile = &VendorProfile{Name: "Local", MaxDuration: time.Second}
+	if d := result.Root.ValidateFor(profile, nil).DiagnosticsByCode(CodeVendorLimit); len(d) != 0 {
+		t.Errorf("不应用词典时不应超出时长: %v", d)
+	}
+	processor := NewAudioProcessor()
+	processor.SetLexiconRegistry(NewLexiconRegistry(MemoryLexiconLoader{"w3c.pls": pls}))
+	if d := result.Root.ValidateFor(profile, processor).DiagnosticsByCode(CodeVendorLimit); len(d) != 1 || !strings.Contains(d[0].Message, "estimated duration") {
+		t.Errorf("应按传入的处理器估算时长: %v", d)
+	}
+	processor.SetLexiconRegistry(NewLexiconRegistry(MemoryLexiconLoader{}))
+	if d := result.Root.ValidateFor(profile, processor).DiagnosticsByCode(CodeVendorLimit); len(d) != 1 || !strings.Contains(d[0].Message, "cannot estimate") {
+		t.Errorf("估算失败时应报告错误: %v", d)
+	}
+}
+
+// TestWalk 测试遍历和修改文档树
+func TestWalk(t *testing.T) {
+	input := `<speak version="1.0" xml:lang="zh-CN"><p><s>第一句<mark name="m1"/></s><s>第二句<sub alias="人工智能">AI</sub></s></p></speak>`
+	parser := NewParser(nil)
+	parse := func() *Speak {
+		result, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+		return result.Root
+	}
+
+	// 先序和后序的顺序、深度、父节点和位置
+	var events []string
+	Walk(parse(), VisitorFuncs{
+		OnEnter: func(c *Cursor) {
+			name := modelName(c.Node())
+			if text, ok := c.Node().(Text); ok {
+				name = text.Content
+			}
+			events = append(events, fmt.Sprintf("+%s@%d:%d", name, c.Depth(), c.Index()))
+			if c.Depth() > 0 && len(c.Ancestors()) != c.Depth() {
+				t.Errorf("祖先链长度错误: %d", len(c.Ancestors()))
+			}
+			if _, ok := c.Node().(*Sub); ok {
+				if _, ok := c.Parent().(*Sentence); !ok {
+					t.Errorf("sub 的父节点应为 s: %T", c.Parent())
+				}
+				c.SkipChildren()
+			}
+		},
+		OnLeave: func(c *Cursor) {
+			events = append(events, "-"+modelName(c.Node()))
+		},
+	})
+	expected := "+speak@0:-1 +p@1:0 +s@2:0 +第一句@3:0 -#text +mark@3:1 -mark -s +s@2:1 +第二句@3:0 -#text +sub@3:1 -sub -s -p -speak"
+	if got := strings.Join(events, " "); got != expected {
+		t.Errorf("遍历顺序错误:\n期望: %s\n实际: %s", expected, got)
+	}
+
+	// 删除 mark，在每个 s 之后插入停顿，把 sub 替换为它的别名
+	speak := parse()
+	original := speak.Content[0].(*Paragraph).Content[0].(*Sentence).Content
+	root := Walk(speak, VisitorFuncs{
+		OnEnter: func(c *Cursor) {
+			switch n := c.Node().(type) {
+			case *Mark:
+				c.Delete()
+			case *Sub:
+				c.Replace(Text{Content: n.Alias})
+			}
+		},
+		OnLeave: func(c *Cursor) {
+			if _, ok := c.Node().(*Sentence); ok {
+				c.InsertAfter(&Break{Time: "500ms"})
+			}
+		},
+	})
+	if root != speak {
+		t.Errorf("没有替换根节点时应返回原节点")
+	}
+	paragraph := speak.Content[0].(*Paragraph)
+	if len(paragraph.Content) != 4 {
+		t.Fatalf("段落应有 4 个子节点: %#v", paragraph.Content)
+	}
+	if br, ok := paragraph.Content[3].(*Break); !ok || br.Time != "500ms" {
+		t.Errorf("s 之后应插入停顿: %#v", paragraph.Content[3])
+	}
+	if len(paragraph.Content[0].(*Sentence).Content) != 1 {
+		t.Errorf("mark 应被删除: %#v", paragraph.Content[0].(*Sentence).Content)
+	}
+	if len(original) != 2 {
+		t.Errorf("修改不应影响原来的内容切片: %#v", original)
+	}
+	if text := plainText(t, speak); text != "第一句第二句人工智能" {
+		t.Errorf("替换后的文本错误: %q", text)
+	}
+
+	// 在当前节点之前插入，插入的节点不会被遍历
+	speak = parse()
+	visited := 0
+	Walk(speak, VisitorFuncs{OnEnter: func(c *Cursor) {
+		visited++
+		if _, ok := c.Node().(*Sentence); ok {
+			c.InsertBefore(&Break{Strength: "weak"})
+		}
+	}})
+	if visited != 9 || len(speak.Content[0].(*Paragraph).Content) != 4 {
+		t.Errorf("插入后遍历的节点数错误: %d", visited)
+	}
+
+	// 停止遍历和替换、删除根节点
+	visited = 0
+	Walk(parse(), VisitorFuncs{OnEnter: func(c *Cursor) {
+		visited++
+		if _, ok := c.Node().(*Mark); ok {
+			c.Stop()
+		}
+	}})
+	if visited != 5 {
+		t.Errorf("停止后不应继续遍历: %d", visited)
+	}
+	replacement := &Speak{Version: "1.1"}
+	if root := Walk(parse(), VisitorFuncs{OnEnter: func(c *Cursor) { c.Replace(replacement) }}); root != replacement {
+		t.Errorf("应返回替换后的根节点: %#v", root)
+	}
+	if root := Walk(parse(), VisitorFuncs{OnLeave: func(c *Cursor) { c.Delete() }}); root != nil {
+		t.Errorf("删除所有节点后应返回 nil: %#v", root)
+	}
+
+	// 插入的 *Text 可以序列化并重新解析
+	speak = parse()
+	Walk(speak, VisitorFuncs{OnEnter: func(c *Cursor) {
+		switch n := c.Node().(type) {
+		case *Mark:
+			c.Replace(&Text{Content: "<一>"})
+		case *Sub:
+			c.InsertBefore(&Text{Content: "和"})
+			c.InsertAfter(&Text{Content: n.Alias})
+		}
+	}})
+	serialized, err := NewSerializer(false).Serialize(speak)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	reparsed, err := parser.Parse(serialized)
+	if err != nil {
+		t.Fatalf("重新解析失败: %v\n%s", err, serialized)
+	}
+	if text := plainText(t, reparsed.Root); text != "第一句<一>第二句和人工智能人工智能" {
+		t.Errorf("*Text 的往返结果错误: %q\n%s", text, serialized)
+	}
+}
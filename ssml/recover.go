@@ -0,0 +1,466 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// predefinedEntities XML 预定义的实体
+var predefinedEntities = map[string]bool{
+	"amp":  true,
+	"lt":   true,
+	"gt":   true,
+	"quot": true,
+	"apos": true,
+}
+
+// emptyElements 内容为空的 SSML 元素，恢复模式下缺少结束标签时立即关闭
+var emptyElements = map[string]bool{
+	"break":   true,
+	"mark":    true,
+	"lexicon": true,
+	"meta":    true,
+	"silence": true,
+}
+
+// openTag 修复过程中尚未关闭的元素
+type openTag struct {
+	name   string
+	offset int
+}
+
+// repairer 恢复模式下的预处理器，修复常见的格式错误并记录每一处修复
+type repairer struct {
+	src      string
+	out      strings.Builder
+	entities map[string]string
+	lines    []int         // 每行起始的字节偏移
+	chunks   []sourceChunk // 输出中每一段对应的原文位置
+	next     int           // 当前标签之后的位置
+	open     []openTag
+	repairs  []Diagnostic
+
+	prologEnd   int  // 输出中 XML 声明等序言结束的位置
+	wrapRoot    bool // 是否在缺少 speak 根元素时补上
+	rootStarted bool // 顶层是否已经出现 speak 元素
+	rootEnded   bool // speak 根元素已经结束，之后只允许空白、注释和处理指令
+	needWrap    bool // 顶层存在 speak 之外的内容
+}
+
+// sourceMap 修复后的文本到原文的位置映射，解析修复后的文本时节点的位置通过它换算为原文中的位置
+type sourceMap struct {
+	chunks []sourceChunk // 按 out 排序
+	lines  []int         // 原文中每行起始的字节偏移
+}
+
+// sourceChunk 修复后文本中从 out 开始的一段：copied 为 true 时逐字节对应原文中从 src 开始的文本，
+// 否则是修复时添加或替换的文本，整段对应原文的 src 处
+type sourceChunk struct {
+	out    int
+	src    int
+	copied bool
+}
+
+// sourceOffset 返回修复后文本中的偏移在原文中的偏移
+func (m *sourceMap) sourceOffset(out int) int {
+	k := sort.Search(len(m.chunks), func(k int) bool { return m.chunks[k].out > out }) - 1
+	if k < 0 {
+		return 0
+	}
+	chunk := m.chunks[k]
+	if chunk.copied {
+		return chunk.src + out - chunk.out
+	}
+	return chunk.src
+}
+
+// position 返回修复后文本中的偏移在原文中的位置
+func (m *sourceMap) position(out int64) Position {
+	return linePosition(m.lines, m.sourceOffset(int(out)))
+}
+
+// insert 记录在修复后文本的 out 处插入了 n 个字节，插入的文本对应原文中 out 处的位置
+func (m *sourceMap) insert(out, n int) {
+	src := m.sourceOffset(out)
+	k := sort.Search(len(m.chunks), func(k int) bool { return m.chunks[k].out >= out })
+	// 跨过 out 的复制段在 out 处拆开，后半段随插入位置之后的文本移动
+	if k > 0 && m.chunks[k-1].copied {
+		prev := m.chunks[k-1]
+		tail := sourceChunk{out: out, src: prev.src + out - prev.out, copied: true}
+		m.chunks = append(m.chunks[:k], append([]sourceChunk{tail}, m.chunks[k:]...)...)
+	}
+	for i := k; i < len(m.chunks); i++ {
+		m.chunks[i].out += n
+	}
+	m.chunks = append(m.chunks[:k], append([]sourceChunk{{out: out, src: src}}, m.chunks[k:]...)...)
+}
+
+// repairSSML 修复未转义的 & 和 <、HTML 实体、未关闭和多余的标签，
+// wrapRoot 为 true 时在缺少 speak 根元素时补上、丢弃 speak 根元素之后的内容，
+// 返回修复后的文本、到原文的位置映射和修复记录
+func repairSSML(src string, entities map[string]string, wrapRoot bool) (string, *sourceMap, []Diagnostic) {
+	r := &repairer{src: src, entities: entities, lines: []int{0}, wrapRoot: wrapRoot}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			r.lines = append(r.lines, i+1)
+		}
+	}
+	r.out.Grow(len(src))
+
+	for i := 0; i < len(src); {
+		if r.rootEnded && r.trailing(i) {
+			r.report(CodeTrailingContent, i, "Content after </speak> was dropped", "remove the content after </speak>")
+			break
+		}
+		switch src[i] {
+		case '<':
+			i = r.markup(i)
+		case '&':
+			r.text(i)
+			i = r.entity(i)
+		default:
+			r.text(i)
+			_, size := utf8.DecodeRuneInString(src[i:])
+			r.copy(i, src[i:i+size])
+			i += size
+		}
+	}
+
+	// 关闭到文末仍未关闭的元素
+	for len(r.open) > 0 {
+		tag := r.open[len(r.open)-1]
+		r.report(CodeAutoClosedElement, tag.offset, fmt.Sprintf("Element <%s> is not closed; closed at end of document", tag.name), "add </"+tag.name+">")
+		r.insert(len(src), "</"+tag.name+">")
+		r.open = r.open[:len(r.open)-1]
+	}
+
+	repaired := r.out.String()
+	source := &sourceMap{chunks: r.chunks, lines: r.lines}
+	if wrapRoot && (r.needWrap || !r.rootStarted) {
+		r.report(CodeWrappedRoot, 0, "Content is not wrapped in a <speak> element; wrapped automatically", "wrap the document in a <speak> element")
+		const open, end = `<speak version="1.0">`, "</speak>"
+		source.insert(r.prologEnd, len(open))
+		source.chunks = append(source.chunks, sourceChunk{out: len(repaired) + len(open), src: len(src)})
+		repaired = repaired[:r.prologEnd] + open + repaired[r.prologEnd:] + end
+	}
+
+	return repaired, source, r.repairs
+}
+
+// copy 原样输出原文中从 at 开始的 s，与上一段连续时合并
+func (r *repairer) copy(at int, s string) {
+	out := r.out.Len()
+	if n := len(r.chunks); n > 0 {
+		last := r.chunks[n-1]
+		if last.copied && last.src+out-last.out == at {
+			r.out.WriteString(s)
+			return
+		}
+	}
+	r.chunks = append(r.chunks, sourceChunk{out: out, src: at, copied: true})
+	r.out.WriteString(s)
+}
+
+// insert 输出修复时添加或替换的文本，对应原文的 at 处
+func (r *repairer) insert(at int, s string) {
+	r.chunks = append(r.chunks, sourceChunk{out: r.out.Len(), src: at})
+	r.out.WriteString(s)
+}
+
+// text 记录顶层出现的文本
+func (r *repairer) text(i int) {
+	if i < len(r.src) && len(r.open) == 0 && !unicode.IsSpace(rune(r.src[i])) {
+		r.needWrap = true
+	}
+}
+
+// trailing 判断 speak 根元素之后 i 处是否为需要丢弃的内容；空白、注释和处理指令照常保留
+func (r *repairer) trailing(i int) bool {
+	rest := r.src[i:]
+	return !unicode.IsSpace(rune(rest[0])) && !strings.HasPrefix(rest, "<!--") && !strings.HasPrefix(rest, "<?")
+}
+
+// markup 处理以 < 开始的标记，返回下一个待处理的位置
+func (r *repairer) markup(i int) int {
+	rest := r.src[i:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		return r.copyUntil(i, "-->", "comment")
+	case strings.HasPrefix(rest, "<![CDATA["):
+		r.text(i)
+		return r.copyUntil(i, "]]>", "CDATA section")
+	case strings.HasPrefix(rest, "<?"):
+		return r.copyUntil(i, "?>", "processing instruction")
+	case strings.HasPrefix(rest, "<!"):
+		return r.copyUntil(i, ">", "declaration")
+	case strings.HasPrefix(rest, "</"):
+		return r.endTag(i)
+	}
+
+	if name := tagName(rest[1:]); name != "" {
+		return r.startTag(i, name)
+	}
+
+	// 不是标签的 <，例如 "a < b"
+	r.text(i)
+	r.report(CodeEscapedMarkup, i, "Unescaped '<' in text; escaped as &lt;", "write &lt;")
+	r.insert(i, "&lt;")
+	return i + 1
+}
+
+// copyUntil 原样复制到 terminator 为止，缺少 terminator 时补上
+func (r *repairer) copyUntil(i int, terminator, what string) int {
+	end := strings.Index(r.src[i:], terminator)
+	if end < 0 {
+		r.report(CodeAutoClosedElement, i, fmt.Sprintf("Unterminated %s; closed at end of document", what), "add "+terminator)
+		r.copy(i, r.src[i:])
+		r.insert(len(r.src), terminator)
+		return len(r.src)
+	}
+
+	end += i + len(terminator)
+	r.copy(i, r.src[i:end])
+	if len(r.open) == 0 && !r.rootStarted && !r.needWrap {
+		r.prologEnd = r.out.Len()
+	}
+	return end
+}
+
+// startTag 处理开始标签和自闭合标签
+func (r *repairer) startTag(i int, name string) int {
+	j := i + 1 + len(name)
+	var quote byte
+	start := r.out.Len()
+	r.copy(i, r.src[i:j])
+
+	for ; j < len(r.src); j++ {
+		c := r.src[j]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0 && c == '<':
+			r.report(CodeEscapedMarkup, j, "Unescaped '<' in attribute value; escaped as &lt;", "write &lt;")
+			r.insert(j, "&lt;")
+			continue
+		case quote != 0 && c == '&':
+			j = r.entity(j) - 1
+			continue
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '=':
+			if k, end := unquotedValue(r.src, j+1); end > k {
+				r.copy(j, r.src[j:k])
+				r.reportSpan(CodeUnquotedAttribute, k, end, fmt.Sprintf("Attribute value %s in <%s> is not quoted; quoted", r.src[k:end], name), "quote the attribute value")
+				r.insert(k, `"`)
+				r.copy(k, r.src[k:end])
+				r.insert(end, `"`)
+				j = end - 1
+				continue
+			}
+		case quote == 0 && c == '<':
+			// 标签没有结束就开始了下一个标签
+			r.report(CodeAutoClosedElement, i, fmt.Sprintf("Start tag <%s> is not terminated; closed with '>'", name), "add '>'")
+			r.insert(j, ">")
+			r.next = j
+			r.pushTag(name, i, strings.HasSuffix(r.out.String()[start:], "/>"))
+			return j
+		}
+		r.copy(j, r.src[j:j+1])
+		if quote == 0 && c == '>' {
+			r.next = j + 1
+			r.pushTag(name, i, strings.HasSuffix(r.out.String()[start:], "/>"))
+			return j + 1
+		}
+	}
+
+	r.report(CodeAutoClosedElement, i, fmt.Sprintf("Start tag <%s> is not terminated; closed with '>'", name), "add '>'")
+	if quote != 0 {
+		r.insert(j, string(quote))
+	}
+	r.insert(j, ">")
+	r.next = j
+	r.pushTag(name, i, false)
+	return j
+}
+
+// pushTag 记录新打开的元素，并跟踪顶层是否只有一个 speak 元素
+func (r *repairer) pushTag(name string, offset int, selfClosing bool) {
+	local := name[strings.LastIndex(name, ":")+1:]
+	if len(r.open) == 0 {
+		if local == "speak" && !r.rootStarted {
+			r.rootStarted = true
+			r.rootEnded = selfClosing && r.wrapRoot && !r.needWrap
+		} else {
+			r.needWrap = true
+		}
+	}
+	if selfClosing {
+		return
+	}
+
+	if emptyElements[local] && !strings.HasPrefix(strings.TrimLeftFunc(r.src[r.next:], unicode.IsSpace), "</"+name+">") {
+		r.report(CodeAutoClosedElement, offset, fmt.Sprintf("Empty element <%s> is not closed; closed immediately", name), "write <"+name+"/>")
+		r.insert(r.next, "</"+name+">")
+		return
+	}
+	r.open = append(r.open, openTag{name: name, offset: offset})
+}
+
+// endTag 处理结束标签：关闭其中未关闭的元素，丢弃没有对应开始标签的结束标签
+func (r *repairer) endTag(i int) int {
+	name := tagName(r.src[i+2:])
+	end := strings.IndexByte(r.src[i:], '>')
+	if name == "" || end < 0 {
+		r.text(i)
+		r.report(CodeEscapedMarkup, i, "Malformed end tag; escaped as text", "write &lt;")
+		r.insert(i, "&lt;")
+		return i + 1
+	}
+	end += i + 1
+
+	match := -1
+	for k := len(r.open) - 1; k >= 0; k-- {
+		if r.open[k].name == name {
+			match = k
+			break
+		}
+	}
+	if match < 0 {
+		r.report(CodeStrayEndTag, i, fmt.Sprintf("End tag </%s> has no matching start tag; dropped", name), "remove </"+name+">")
+		return end
+	}
+
+	for k := len(r.open) - 1; k > match; k-- {
+		tag := r.open[k]
+		r.report(CodeAutoClosedElement, tag.offset, fmt.Sprintf("Element <%s> is not closed; closed before </%s>", tag.name, name), "add </"+tag.name+">")
+		r.insert(i, "</"+tag.name+">")
+	}
+	r.open = r.open[:match]
+	r.copy(i, r.src[i:end])
+	// 关闭的是 speak 根元素
+	if match == 0 && r.rootStarted && r.wrapRoot && !r.needWrap {
+		r.rootEnded = true
+	}
+	return end
+}
+
+// entity 处理以 & 开始的实体引用，返回下一个待处理的位置
+func (r *repairer) entity(i int) int {
+	rest := r.src[i+1:]
+	semicolon := strings.IndexByte(rest, ';')
+	if semicolon > 0 && semicolon <= 32 {
+		ref := rest[:semicolon]
+		end := i + 1 + semicolon + 1
+
+		if isCharRef(ref) || predefinedEntities[ref] {
+			r.copy(i, r.src[i:end])
+			return end
+		}
+		if value, ok := r.entities[ref]; ok && tagName(ref) == ref {
+			r.report(CodeRepairedEntity, i, fmt.Sprintf("Entity &%s; is not defined in XML; replaced with its value", ref), "use a numeric character reference")
+			var escaped strings.Builder
+			xml.EscapeText(&escaped, []byte(value))
+			r.insert(i, escaped.String())
+			return end
+		}
+	}
+
+	r.report(CodeRepairedEntity, i, "Unescaped '&'; escaped as &amp;", "write &amp;")
+	r.insert(i, "&amp;")
+	return i + 1
+}
+
+// report 记录原文 offset 处的一处修复
+func (r *repairer) report(code DiagnosticCode, offset int, message, fix string) {
+	r.reportSpan(code, offset, offset, message, fix)
+}
+
+// reportSpan 记录原文 [start, end) 范围内的一处修复
+func (r *repairer) reportSpan(code DiagnosticCode, start, end int, message, fix string) {
+	r.repairs = append(r.repairs, Diagnostic{
+		Code:     code,
+		Severity: SeverityWarning,
+		Message:  message,
+		Span:     Span{Start: r.position(start), End: r.position(end)},
+		Fix:      fix,
+	})
+}
+
+// position 返回原文中偏移对应的位置
+func (r *repairer) position(offset int) Position {
+	return linePosition(r.lines, offset)
+}
+
+// linePosition 根据每行起始的偏移返回 offset 对应的行列
+func linePosition(lines []int, offset int) Position {
+	line := sort.Search(len(lines), func(k int) bool { return lines[k] > offset })
+	return Position{Line: line, Column: offset - lines[line-1] + 1, Offset: int64(offset)}
+}
+
+// unquotedValue 返回 src 中 i 处（跳过空白）没有引号的属性值的范围，值以引号开始或为空时 end == k
+func unquotedValue(src string, i int) (k, end int) {
+	k = i
+	for k < len(src) && unicode.IsSpace(rune(src[k])) {
+		k++
+	}
+	for end = k; end < len(src); end++ {
+		c := src[end]
+		if unicode.IsSpace(rune(c)) || c == '"' || c == '\'' || c == '<' || c == '>' || strings.HasPrefix(src[end:], "/>") {
+			break
+		}
+	}
+	return k, end
+}
+
+// tagName 读取 s 开头的元素名，不是合法的名称时返回空
+func tagName(s string) string {
+	for i, c := range s {
+		if unicode.IsLetter(c) || c == '_' || c == ':' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(c) || c == '-' || c == '.') {
+			continue
+		}
+		return s[:i]
+	}
+	return s
+}
+
+// isCharRef 判断是否为数字字符引用（#123 或 #x7B）
+func isCharRef(ref string) bool {
+	if len(ref) < 2 || ref[0] != '#' {
+		return false
+	}
+	digits, base := ref[1:], 10
+	if digits[0] == 'x' {
+		digits, base = digits[1:], 16
+	}
+	if digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if !(c >= '0' && c <= '9' || base == 16 && (c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F')) {
+			return false
+		}
+	}
+	return true
+}
+
+// recoveryEntities 返回恢复模式识别的实体：HTML 实体加上配置中的实体
+func (p *Parser) recoveryEntities() map[string]string {
+	if len(p.config.Entities) == 0 {
+		return xml.HTMLEntity
+	}
+	entities := make(map[string]string, len(xml.HTMLEntity)+len(p.config.Entities))
+	for name, value := range xml.HTMLEntity {
+		entities[name] = value
+	}
+	for name, value := range p.config.Entities {
+		entities[name] = value
+	}
+	return entities
+}
@@ -0,0 +1,216 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CustomElement 自定义元素节点，序列化和音频处理时按 ElementName 查找处理函数
+type CustomElement interface {
+	ElementName() xml.Name
+}
+
+// ElementHandler 自定义元素在解析、序列化和音频处理三个阶段的处理函数
+type ElementHandler struct {
+	// Parse 解析元素，返回的节点会加入父元素的内容中；返回 nil 表示丢弃该元素。
+	// Parse 必须通过 ctx.ParseContent 或 ctx.Skip 读取到元素的结束标签；
+	// 两者都没有调用时，解析器会跳过元素的剩余内容并报告 CodeUnconsumedElement
+	Parse func(ctx *ElementParseContext, start xml.StartElement) (interface{}, error)
+	// Serialize 序列化 Parse 返回的节点，为空时无法序列化该节点
+	Serialize func(ctx *ElementSerializeContext, node interface{}) error
+	// Process 生成音频片段和指令，为空时只处理子节点
+	Process func(ctx *ElementProcessContext, node interface{})
+}
+
+// ElementRegistry 自定义元素注册表，按限定名（命名空间 + 本地名）查找处理函数
+type ElementRegistry struct {
+	mu       sync.RWMutex
+	handlers map[xml.Name]*ElementHandler
+}
+
+// NewElementRegistry 创建自定义元素注册表
+func NewElementRegistry() *ElementRegistry {
+	return &ElementRegistry{
+		handlers: make(map[xml.Name]*ElementHandler),
+	}
+}
+
+// DefaultElementRegistry 解析器、序列化器和音频处理器默认使用的注册表
+var DefaultElementRegistry = NewElementRegistry()
+
+// RegisterElement 在默认注册表中注册自定义元素
+func RegisterElement(name xml.Name, handler ElementHandler) {
+	DefaultElementRegistry.Register(name, handler)
+}
+
+// Register 注册自定义元素，同名元素会被覆盖
+func (r *ElementRegistry) Register(name xml.Name, handler ElementHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[name] = &handler
+}
+
+// Unregister 移除自定义元素
+func (r *ElementRegistry) Unregister(name xml.Name) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.handlers, name)
+}
+
+// Lookup 查找自定义元素的处理函数
+func (r *ElementRegistry) Lookup(name xml.Name) (*ElementHandler, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[name]
+	return handler, ok
+}
+
+// ElementParseContext 自定义元素的解析上下文
+type ElementParseContext struct {
+	parser   *Parser
+	decoder  *tokenDecoder
+	start    xml.StartElement
+	pos      Position
+	consumed bool // 已经读取到元素的结束标签
+}
+
+// Position 返回元素的起始位置
+func (c *ElementParseContext) Position() Position {
+	return c.pos
+}
+
+// Span 返回从元素起始到当前解析位置的区间
+func (c *ElementParseContext) Span() Span {
+	return Span{Start: c.pos, End: c.decoder.position()}
+}
+
+// ParseContent 按内置规则解析子节点，直到元素结束；元素已经读取完时返回 nil
+func (c *ElementParseContext) ParseContent() ([]interface{}, error) {
+	if c.consumed {
+		return nil, nil
+	}
+	c.consumed = true
+	return c.parser.parseContent(c.decoder, c.start.Name.Local)
+}
+
+// Skip 跳过元素的全部子节点；元素已经读取完时什么也不做
+func (c *ElementParseContext) Skip() error {
+	if c.consumed {
+		return nil
+	}
+	c.consumed = true
+	return c.decoder.Skip()
+}
+
+// ElementSerializeContext 自定义元素的序列化上下文
+type ElementSerializeContext struct {
+	serializer *Serializer
+	writer     *ssmlWriter
+	depth      int
+}
+
+// WriteElement 写入元素，命名空间前缀、缩进和子节点按内置规则处理
+func (c *ElementSerializeContext) WriteElement(name xml.Name, attrs []xml.Attr, content []interface{}) error {
+	element := &UnknownElement{XMLName: name, Attrs: attrs, Content: content}
+	return c.serializer.serializeUnknown(c.writer, element, c.depth)
+}
+
+// WriteContent 在当前层级写入子节点
+func (c *ElementSerializeContext) WriteContent(content []interface{}) error {
+	return c.serializer.serializeContent(c.writer, content, c.depth)
+}
+
+// ElementProcessContext 自定义元素的音频处理上下文
+type ElementProcessContext struct {
+	ctx *processingContext
+}
+
+// Properties 返回当前音频属性的副本
+func (c *ElementProcessContext) Properties() *AudioProperties {
+	return c.ctx.getCurrentProperties()
+}
+
+// CurrentTime 返回当前在音频时间轴上的位置
+func (c *ElementProcessContext) CurrentTime() time.Duration {
+	return c.ctx.currentTime
+}
+
+// ProcessContent 在 props 作用域内处理子节点，props 为 nil 时沿用当前属性
+func (c *ElementProcessContext) ProcessContent(content []interface{}, props *AudioProperties) {
+	if props != nil {
+		c.ctx.pushProperties(props)
+		defer c.ctx.popProperties()
+	}
+	for _, child := range content {
+		c.ctx.processContent(child)
+	}
+}
+
+// AddText 以当前属性添加一段文本
+func (c *ElementProcessContext) AddText(text string) {
+	c.ctx.addTextSegment(text, c.ctx.getCurrentProperties())
+}
+
+// AddInstruction 在当前位置添加音频指令，时间轴按指令的 Duration 前进
+func (c *ElementProcessContext) AddInstruction(instruction AudioInstruction) {
+	instruction.Position = c.ctx.textPosition
+	instruction.StartTime = c.ctx.currentTime
+	c.ctx.result.Instructions = append(c.ctx.result.Instructions, instruction)
+	c.ctx.currentTime += instruction.Duration
+}
+
+// parseCustom 使用注册的处理函数解析元素；处理函数没有读取到结束标签时，
+// 跳过元素的剩余内容，记录到解码器中，解析结束后报告
+func (p *Parser) parseCustom(decoder *tokenDecoder, start xml.StartElement, pos Position, handler *ElementHandler) (interface{}, error) {
+	ctx := &ElementParseContext{parser: p, decoder: decoder, start: start, pos: pos}
+	node, err := handler.Parse(ctx, start)
+	if cerr := contextError(err); cerr != nil {
+		return nil, cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", pos, qualifiedName(start.Name), err)
+	}
+	if !ctx.consumed {
+		if err := ctx.Skip(); err != nil {
+			return nil, err
+		}
+		decoder.dropped = append(decoder.dropped, Diagnostic{
+			Code:    CodeUnconsumedElement,
+			Message: fmt.Sprintf("Handler for <%s> did not read to its end tag; the remaining content was skipped", qualifiedName(start.Name)),
+			Span:    Span{Start: pos, End: decoder.position()},
+			Fix:     "call ParseContent or Skip in the Parse handler",
+		})
+	}
+	return node, nil
+}
+
+// serializeCustom 使用注册的处理函数序列化自定义元素
+func (s *Serializer) serializeCustom(builder *ssmlWriter, element CustomElement, depth int) error {
+	handler, ok := s.registry().Lookup(element.ElementName())
+	if !ok || handler.Serialize == nil {
+		return fmt.Errorf("no serializer registered for %s", qualifiedName(element.ElementName()))
+	}
+	ctx := &ElementSerializeContext{serializer: s, writer: builder, depth: depth}
+	return handler.Serialize(ctx, element)
+}
+
+// processCustom 使用注册的处理函数处理自定义元素
+func (ctx *processingContext) processCustom(element CustomElement) {
+	handler, ok := ctx.processor.registry().Lookup(element.ElementName())
+	if ok && handler.Process != nil {
+		handler.Process(&ElementProcessContext{ctx: ctx}, element)
+		return
+	}
+
+	// 没有处理函数时只处理子节点
+	if container, ok := element.(SSMLElement); ok {
+		for _, child := range container.GetContent() {
+			ctx.processContent(child)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package ssml
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DocumentScanner 逐个读取输入中的多个 SSML 文档，用法与 bufio.Scanner 相同：
+//
+//	scanner := parser.NewDocumentScanner(file)
+//	defer scanner.Close()
+//	for scanner.Scan() {
+//		result := scanner.Result()
+//	}
+//	if err := scanner.Err(); err != nil { ... }
+//
+// Scan 返回 false 时扫描器已释放解码器的状态；提前结束循环时应调用 Close
+type DocumentScanner struct {
+	parser *Parser
+	ctx    context.Context
+
+	// 连接在一起的 XML 文档
+	decoder *tokenDecoder
+	limited *limitedReader
+	pending []Diagnostic // 两个文档之间的诊断信息，记录到下一个文档中
+
+	// JSON Lines
+	lines  *bufio.Reader
+	field  string
+	record []byte
+
+	result *ParseResult
+	line   int
+	index  int
+	err    error
+	done   bool
+}
+
+// NewDocumentScanner 创建读取连续 speak 文档的扫描器，例如多个文档直接拼接成的文件。
+// 每个文档有独立的 ParseResult，位置相对于整个输入；资源限制按文档计算，不支持恢复模式
+func (p *Parser) NewDocumentScanner(reader io.Reader) *DocumentScanner {
+	scanner := &DocumentScanner{parser: p, ctx: context.Background()}
+	if p.config.MaxInputBytes > 0 {
+		scanner.limited = &limitedReader{reader: reader, max: p.config.MaxInputBytes}
+		reader = scanner.limited
+	}
+	scanner.decoder = newTokenDecoder(scanner.ctx, reader)
+	return scanner
+}
+
+// recordOverhead JSON Lines 中一行除 SSML 字段之外允许的字节数
+const recordOverhead = 64 << 10
+
+// NewJSONLScanner 创建读取 JSON Lines 的扫描器，每行是一个对象，SSML 文档在 field 字段中（为空时为 "ssml"）。
+// 每个文档按 Parse 解析，位置相对于该字段的内容；无法解码的行产生只包含 CodeInvalidRecord 错误的结果。
+// 设置了 MaxInputBytes 时，超过 6*MaxInputBytes+64KiB 的行在读入内存之前被拒绝，扫描停止，Err 返回 ErrInputTooLarge
+func (p *Parser) NewJSONLScanner(reader io.Reader, field string) *DocumentScanner {
+	if field == "" {
+		field = "ssml"
+	}
+	return &DocumentScanner{
+		parser: p,
+		ctx:    context.Background(),
+		lines:  bufio.NewReader(reader),
+		field:  field,
+	}
+}
+
+// SetContext 设置扫描使用的上下文，取消后 Scan 返回 false，Err 返回 ctx.Err()
+func (s *DocumentScanner) SetContext(ctx context.Context) {
+	s.ctx = ctx
+	if s.decoder != nil {
+		s.decoder.ctx = ctx
+	}
+}
+
+// Scan 读取下一个文档，没有更多文档或遇到无法继续的错误时返回 false
+func (s *DocumentScanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	s.result = nil
+	s.record = nil
+
+	if s.lines != nil {
+		return s.scanRecord()
+	}
+	return s.scanDocument()
+}
+
+// Result 返回当前文档的解析结果；文档本身的错误记录在其中，不影响后续文档。
+// Scan 因 XML 语法错误返回 false 时，返回出错文档的部分结果
+func (s *DocumentScanner) Result() *ParseResult {
+	return s.result
+}
+
+// Line 返回当前文档开始的行号：XML 输入中 speak 开始标签所在的行，JSON Lines 中记录所在的行
+func (s *DocumentScanner) Line() int {
+	return s.line
+}
+
+// Index 返回当前文档的序号，从 0 开始
+func (s *DocumentScanner) Index() int {
+	return s.index - 1
+}
+
+// Record 返回 JSON Lines 中当前记录的原始内容，可用于读取 id 等其他字段；XML 输入时为 nil
+func (s *DocumentScanner) Record() []byte {
+	return s.record
+}
+
+// Err 返回使扫描停止的错误，正常读完输入时为 nil
+func (s *DocumentScanner) Err() error {
+	return s.err
+}
+
+// Close 停止扫描并释放解码器，不关闭底层的 reader。
+// 可以多次调用，Scan 返回 false 之后调用没有影响；之后 Scan 返回 false，已返回的结果仍然可用
+func (s *DocumentScanner) Close() error {
+	s.finish()
+	return nil
+}
+
+// fail 停止扫描并记录错误
+func (s *DocumentScanner) fail(err error) bool {
+	s.finish()
+	s.err = err
+	return false
+}
+
+// finish 停止扫描，释放解码器
+func (s *DocumentScanner) finish() {
+	s.done = true
+	s.decoder = nil
+}
+
+// newResult 创建下一个文档的解析结果
+func (s *DocumentScanner) newResult() *ParseResult {
+	s.index++
+	return &ParseResult{
+		Warnings: []Diagnostic{},
+		Errors:   []Diagnostic{},
+	}
+}
+
+// scanDocument 从 XML 输入中读取下一个 speak 文档
+func (s *DocumentScanner) scanDocument() bool {
+	p := s.parser
+	for {
+		token, err := s.decoder.Token()
+		if err == io.EOF {
+			s.finish()
+			return false
+		}
+		if err != nil {
+			result := s.newResult()
+			err = p.reportParseError(s.decoder, err, CodeXMLSyntax, result)
+			s.result = result
+			return s.fail(err)
+		}
+
+		se, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local != "speak" {
+			s.pending = append(s.pending, Diagnostic{
+				Code:     CodeInvalidRoot,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("Root element should be 'speak', found '%s'", se.Name.Local),
+				Span:     Span{Start: s.decoder.tokenStart, End: s.decoder.position()},
+				Fix:      "wrap the document in a <speak> element",
+			})
+			if err := s.decoder.Skip(); err != nil {
+				s.result = s.newResult()
+				return s.fail(err)
+			}
+			continue
+		}
+
+		// 每个文档单独计算资源限制、命名空间和 xml:space 作用域
+		s.decoder.limits = &parseLimits{}
+		s.decoder.coreNamespace = ""
+		s.decoder.namespaces = nil
+		s.decoder.preserveSpace = false
+		if s.limited != nil {
+			s.limited.read = 0
+		}
+
+		result := s.newResult()
+		for _, d := range s.pending {
+			p.report(result, d)
+		}
+		s.pending = nil
+		s.result = result
+		s.line = s.decoder.tokenStart.Line
+
+		speak, err := p.parseDocument(s.decoder, se, result)
+		if err != nil {
+			// 文档内部的语法错误之后无法找到下一个文档的开始
+			return s.fail(err)
+		}
+		result.Root = speak
+		_ = p.reportDropped(s.decoder, result)
+		_ = p.validate(s.ctx, speak, result)
+		return true
+	}
+}
+
+// scanRecord 从 JSON Lines 输入中读取下一条记录
+func (s *DocumentScanner) scanRecord() bool {
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return s.fail(err)
+		}
+
+		line, err := s.readLine()
+		if errors.Is(err, ErrInputTooLarge) {
+			s.line++
+			result := s.newResult()
+			s.parser.report(result, Diagnostic{
+				Code:     CodeMaxInputBytes,
+				Severity: SeverityError,
+				Message:  err.Error(),
+				Fix:      "split the document or raise ValidationConfig.MaxInputBytes",
+			})
+			s.result = result
+			return s.fail(err)
+		}
+		if err != nil && err != io.EOF {
+			return s.fail(err)
+		}
+		if len(line) == 0 && err == io.EOF {
+			s.done = true
+			return false
+		}
+		s.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		s.record = line
+		s.result = s.parseRecord(line)
+		return true
+	}
+}
+
+// readLine 读取下一行，边读边检查长度，超过 maxRecordBytes 时返回 *LimitError，不把整行读入内存
+func (s *DocumentScanner) readLine() ([]byte, error) {
+	max := s.maxRecordBytes()
+	var line []byte
+	for {
+		chunk, err := s.lines.ReadSlice('\n')
+		if max > 0 && int64(len(line)+len(chunk)) > max {
+			return nil, &LimitError{
+				Code:    CodeMaxInputBytes,
+				Err:     ErrInputTooLarge,
+				Message: fmt.Sprintf("line %d: record exceeds %d bytes", s.line+1, max),
+			}
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// maxRecordBytes 一行的最大字节数，0 表示不限制；JSON 转义后文档中的每个字节最多占 6 个字节（如 \u003c）
+func (s *DocumentScanner) maxRecordBytes() int64 {
+	max := s.parser.config.MaxInputBytes
+	if max <= 0 {
+		return 0
+	}
+	return 6*max + recordOverhead
+}
+
+// parseRecord 解析一条 JSON 记录中的 SSML 文档
+func (s *DocumentScanner) parseRecord(line []byte) *ParseResult {
+	var fields map[string]json.RawMessage
+	var content string
+	err := json.Unmarshal(line, &fields)
+	if err == nil {
+		raw, ok := fields[s.field]
+		if !ok {
+			err = fmt.Errorf("missing field %q", s.field)
+		} else if err = json.Unmarshal(raw, &content); err != nil {
+			err = fmt.Errorf("field %q is not a string", s.field)
+		}
+	}
+	if err != nil {
+		result := s.newResult()
+		s.parser.report(result, Diagnostic{
+			Code:     CodeInvalidRecord,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("line %d: invalid record: %v", s.line, err),
+			Fix:      fmt.Sprintf(`write one JSON object per line with the document in "%s"`, s.field),
+		})
+		return result
+	}
+
+	s.index++
+	result, _ := s.parser.ParseContext(s.ctx, content)
+	return result
+}
@@ -1,15 +1,100 @@
 package ssml
 
 import (
+	"encoding/xml"
 	"fmt"
 	"html"
 	"strings"
 )
 
+// xmlNamespace xml 前缀对应的命名空间
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// ssmlWriter 序列化输出，记录每层元素声明的命名空间前缀
+type ssmlWriter struct {
+	strings.Builder
+	scopes    []map[string]string // 每层的 命名空间 URI -> 前缀
+	generated int                 // 已生成的前缀数量
+}
+
+// declare 记录 depth 层元素上的命名空间声明，并丢弃更深层的声明
+func (w *ssmlWriter) declare(depth int, attrs []xml.Attr) {
+	for len(w.scopes) < depth {
+		w.scopes = append(w.scopes, nil)
+	}
+	w.scopes = w.scopes[:depth]
+
+	declared := make(map[string]string)
+	for _, attr := range attrs {
+		switch {
+		case attr.Name.Space == "xmlns":
+			declared[attr.Value] = attr.Name.Local
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			declared[attr.Value] = ""
+		}
+	}
+	w.scopes = append(w.scopes, declared)
+}
+
+// qualify 返回名称在 depth 层的限定名；命名空间没有声明时生成前缀，并返回需要写入的声明
+func (w *ssmlWriter) qualify(depth int, name xml.Name, attr bool) (string, string) {
+	switch name.Space {
+	case "":
+		return name.Local, ""
+	case "xmlns":
+		return "xmlns:" + name.Local, ""
+	case xmlNamespace:
+		return "xml:" + name.Local, ""
+	}
+
+	for i := depth; i >= 0 && i < len(w.scopes); i-- {
+		if prefix, ok := w.scopes[i][name.Space]; ok {
+			// 属性不受默认命名空间影响，必须使用前缀
+			if prefix == "" && attr {
+				continue
+			}
+			if prefix == "" {
+				return name.Local, ""
+			}
+			return prefix + ":" + name.Local, ""
+		}
+	}
+
+	// 未声明的前缀（如 amazon:effect）按原样输出
+	if !strings.ContainsAny(name.Space, ":/") {
+		return name.Space + ":" + name.Local, ""
+	}
+
+	prefix := w.preferredPrefix(name.Space)
+	if prefix == "" {
+		w.generated++
+		prefix = fmt.Sprintf("ns%d", w.generated)
+	}
+	w.scopes[depth][name.Space] = prefix
+	return prefix + ":" + name.Local, fmt.Sprintf(` xmlns:%s="%s"`, prefix, html.EscapeString(name.Space))
+}
+
+// preferredPrefix 返回厂商命名空间的惯用前缀，前缀已被占用时返回空
+func (w *ssmlWriter) preferredPrefix(space string) string {
+	if canonicalNamespace(space) != MSTTSNamespace {
+		return ""
+	}
+	for _, scope := range w.scopes {
+		for _, prefix := range scope {
+			if prefix == "mstts" {
+				return ""
+			}
+		}
+	}
+	return "mstts"
+}
+
 // Serializer SSML 序列化器
 type Serializer struct {
-	Pretty bool
-	Indent string
+	Pretty   bool
+	Indent   string
+	Registry *ElementRegistry // 自定义元素注册表，为空时使用 DefaultElementRegistry
+	Encoding string           // Encode 输出的字符集，如 GBK、GB18030、UTF-16，为空时为 UTF-8
 }
 
 // NewSerializer 创建新的序列化器
@@ -24,6 +109,14 @@ func NewSerializer(pretty bool) *Serializer {
 	}
 }
 
+// registry 返回序列化使用的自定义元素注册表
+func (s *Serializer) registry() *ElementRegistry {
+	if s.Registry != nil {
+		return s.Registry
+	}
+	return DefaultElementRegistry
+}
+
 // escapeString 转义字符串中的 XML 特殊字符
 func (s *Serializer) escapeString(str string) string {
 	return html.EscapeString(str)
@@ -31,14 +124,37 @@ func (s *Serializer) escapeString(str string) string {
 
 // Serialize 将 Speak 结构体序列化为 SSML 字符串
 func (s *Serializer) Serialize(speak *Speak) (string, error) {
+	return s.serialize(speak, "UTF-8")
+}
+
+// Encode 将 Speak 结构体序列化为 Encoding 编码的 SSML，XML 声明中的 encoding 与之一致；
+// 目标编码无法表示的字符写为数字字符引用
+func (s *Serializer) Encode(speak *Speak) ([]byte, error) {
+	charset := s.Encoding
+	if charset == "" {
+		charset = "UTF-8"
+	}
+	if _, err := lookupEncoding(charset); err != nil {
+		return nil, err
+	}
+
+	output, err := s.serialize(speak, charset)
+	if err != nil {
+		return nil, err
+	}
+	return encodeOutput(output, charset)
+}
+
+// serialize 序列化 speak 元素，XML 声明中的编码为 charset
+func (s *Serializer) serialize(speak *Speak, charset string) (string, error) {
 	if speak == nil {
 		return "", fmt.Errorf("speak is nil")
 	}
 
-	var builder strings.Builder
+	var builder ssmlWriter
 	
 	// 写入 XML 声明
-	builder.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	builder.WriteString(`<?xml version="1.0" encoding="` + s.escapeString(charset) + `"?>`)
 	if s.Pretty {
 		builder.WriteString("\n")
 	}
@@ -52,7 +168,7 @@ func (s *Serializer) Serialize(speak *Speak) (string, error) {
 }
 
 // serializeSpeak 序列化 speak 元素
-func (s *Serializer) serializeSpeak(builder *strings.Builder, speak *Speak, depth int) error {
+func (s *Serializer) serializeSpeak(builder *ssmlWriter, speak *Speak, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<speak")
 
@@ -63,6 +179,15 @@ func (s *Serializer) serializeSpeak(builder *strings.Builder, speak *Speak, dept
 	if speak.Lang != "" {
 		builder.WriteString(fmt.Sprintf(` xml:lang="%s"`, s.escapeString(speak.Lang)))
 	}
+	if speak.OnLangFailure != "" {
+		builder.WriteString(fmt.Sprintf(` onlangfailure="%s"`, s.escapeString(speak.OnLangFailure)))
+	}
+
+	attrs := make([]xml.Attr, 0, len(speak.Namespaces)+len(speak.Attrs))
+	for _, namespace := range speak.Namespaces {
+		attrs = append(attrs, namespace.Attr())
+	}
+	s.writeAttrs(builder, depth, append(attrs, speak.Attrs...))
 
 	if len(speak.Content) == 0 {
 		builder.WriteString("/>")
@@ -91,16 +216,25 @@ func (s *Serializer) serializeSpeak(builder *strings.Builder, speak *Speak, dept
 	return nil
 }
 
+// serializeText 序列化文本
+func (s *Serializer) serializeText(builder *ssmlWriter, text string, depth int) {
+	s.writeIndent(builder, depth)
+	builder.WriteString(s.escapeString(text))
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+}
+
 // serializeContent 序列化内容
-func (s *Serializer) serializeContent(builder *strings.Builder, content []interface{}, depth int) error {
+func (s *Serializer) serializeContent(builder *ssmlWriter, content []interface{}, depth int) error {
 	for _, item := range content {
 		switch v := item.(type) {
 		case Text:
-			s.writeIndent(builder, depth)
-			builder.WriteString(s.escapeString(v.Content))
-			if s.Pretty {
-				builder.WriteString("\n")
-			}
+			s.serializeText(builder, v.Content, depth)
+
+		case *Text:
+			// Walk 等编辑树的代码可能插入 *Text
+			s.serializeText(builder, v.Content, depth)
 
 		case *Audio:
 			if err := s.serializeAudio(builder, v, depth); err != nil {
@@ -117,6 +251,36 @@ func (s *Serializer) serializeContent(builder *strings.Builder, content []interf
 				return err
 			}
 
+		case *Lexicon:
+			if err := s.serializeLexicon(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *Lookup:
+			if err := s.serializeLookup(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *Mark:
+			if err := s.serializeMark(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *Meta:
+			if err := s.serializeMeta(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *Metadata:
+			if err := s.serializeMetadata(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *Desc:
+			if err := s.serializeDesc(builder, v, depth); err != nil {
+				return err
+			}
+
 		case *Paragraph:
 			if err := s.serializeParagraph(builder, v, depth); err != nil {
 				return err
@@ -137,6 +301,11 @@ func (s *Serializer) serializeContent(builder *strings.Builder, content []interf
 				return err
 			}
 
+		case *SayAs:
+			if err := s.serializeSayAs(builder, v, depth); err != nil {
+				return err
+			}
+
 		case *Sub:
 			if err := s.serializeSub(builder, v, depth); err != nil {
 				return err
@@ -152,6 +321,41 @@ func (s *Serializer) serializeContent(builder *strings.Builder, content []interf
 				return err
 			}
 
+		case *UnknownElement:
+			if err := s.serializeUnknown(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *Lang:
+			if err := s.serializeLang(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *MSTTSExpressAs:
+			if err := s.serializeMSTTSExpressAs(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *MSTTSSilence:
+			if err := s.serializeMSTTSSilence(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *AmazonEffect:
+			if err := s.serializeAmazonEffect(builder, v, depth); err != nil {
+				return err
+			}
+
+		case *AmazonDomain:
+			if err := s.serializeAmazonDomain(builder, v, depth); err != nil {
+				return err
+			}
+
+		case CustomElement:
+			if err := s.serializeCustom(builder, v, depth); err != nil {
+				return err
+			}
+
 		default:
 			return fmt.Errorf("unknown content type: %T", v)
 		}
@@ -160,7 +364,7 @@ func (s *Serializer) serializeContent(builder *strings.Builder, content []interf
 }
 
 // serializeAudio 序列化 audio 元素
-func (s *Serializer) serializeAudio(builder *strings.Builder, audio *Audio, depth int) error {
+func (s *Serializer) serializeAudio(builder *ssmlWriter, audio *Audio, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<audio")
 
@@ -168,6 +372,8 @@ func (s *Serializer) serializeAudio(builder *strings.Builder, audio *Audio, dept
 		builder.WriteString(fmt.Sprintf(` src="%s"`, s.escapeString(audio.Src)))
 	}
 
+	s.writeAttrs(builder, depth, audio.Attrs)
+
 	if len(audio.Content) == 0 {
 		builder.WriteString("/>")
 		if s.Pretty {
@@ -195,7 +401,7 @@ func (s *Serializer) serializeAudio(builder *strings.Builder, audio *Audio, dept
 }
 
 // serializeBreak 序列化 break 元素
-func (s *Serializer) serializeBreak(builder *strings.Builder, breakElem *Break, depth int) error {
+func (s *Serializer) serializeBreak(builder *ssmlWriter, breakElem *Break, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<break")
 
@@ -206,6 +412,8 @@ func (s *Serializer) serializeBreak(builder *strings.Builder, breakElem *Break,
 		builder.WriteString(fmt.Sprintf(` strength="%s"`, s.escapeString(breakElem.Strength)))
 	}
 
+	s.writeAttrs(builder, depth, breakElem.Attrs)
+
 	builder.WriteString("/>")
 	if s.Pretty {
 		builder.WriteString("\n")
@@ -215,7 +423,7 @@ func (s *Serializer) serializeBreak(builder *strings.Builder, breakElem *Break,
 }
 
 // serializeEmphasis 序列化 emphasis 元素
-func (s *Serializer) serializeEmphasis(builder *strings.Builder, emphasis *Emphasis, depth int) error {
+func (s *Serializer) serializeEmphasis(builder *ssmlWriter, emphasis *Emphasis, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<emphasis")
 
@@ -223,6 +431,8 @@ func (s *Serializer) serializeEmphasis(builder *strings.Builder, emphasis *Empha
 		builder.WriteString(fmt.Sprintf(` level="%s"`, s.escapeString(emphasis.Level)))
 	}
 
+	s.writeAttrs(builder, depth, emphasis.Attrs)
+
 	if len(emphasis.Content) == 0 {
 		builder.WriteString("/>")
 		if s.Pretty {
@@ -249,10 +459,162 @@ func (s *Serializer) serializeEmphasis(builder *strings.Builder, emphasis *Empha
 	return nil
 }
 
+// serializeLexicon 序列化 lexicon 元素
+func (s *Serializer) serializeLexicon(builder *ssmlWriter, lexicon *Lexicon, depth int) error {
+	s.writeIndent(builder, depth)
+	builder.WriteString("<lexicon")
+
+	if lexicon.URI != "" {
+		builder.WriteString(fmt.Sprintf(` uri="%s"`, s.escapeString(lexicon.URI)))
+	}
+	if lexicon.ID != "" {
+		builder.WriteString(fmt.Sprintf(` xml:id="%s"`, s.escapeString(lexicon.ID)))
+	}
+	if lexicon.Type != "" {
+		builder.WriteString(fmt.Sprintf(` type="%s"`, s.escapeString(lexicon.Type)))
+	}
+
+	s.writeAttrs(builder, depth, lexicon.Attrs)
+
+	builder.WriteString("/>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeLookup 序列化 lookup 元素
+func (s *Serializer) serializeLookup(builder *ssmlWriter, lookup *Lookup, depth int) error {
+	s.writeIndent(builder, depth)
+	builder.WriteString("<lookup")
+
+	if lookup.Ref != "" {
+		builder.WriteString(fmt.Sprintf(` ref="%s"`, s.escapeString(lookup.Ref)))
+	}
+
+	s.writeAttrs(builder, depth, lookup.Attrs)
+
+	if len(lookup.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, lookup.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</lookup>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeMark 序列化 mark 元素
+func (s *Serializer) serializeMark(builder *ssmlWriter, mark *Mark, depth int) error {
+	s.writeIndent(builder, depth)
+	builder.WriteString(fmt.Sprintf(`<mark name="%s"`, s.escapeString(mark.Name)))
+	s.writeAttrs(builder, depth, mark.Attrs)
+	builder.WriteString("/>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeMeta 序列化 meta 元素
+func (s *Serializer) serializeMeta(builder *ssmlWriter, meta *Meta, depth int) error {
+	s.writeIndent(builder, depth)
+	builder.WriteString("<meta")
+
+	if meta.Name != "" {
+		builder.WriteString(fmt.Sprintf(` name="%s"`, s.escapeString(meta.Name)))
+	}
+	if meta.HTTPEquiv != "" {
+		builder.WriteString(fmt.Sprintf(` http-equiv="%s"`, s.escapeString(meta.HTTPEquiv)))
+	}
+	builder.WriteString(fmt.Sprintf(` content="%s"`, s.escapeString(meta.Value)))
+
+	s.writeAttrs(builder, depth, meta.Attrs)
+
+	builder.WriteString("/>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeMetadata 序列化 metadata 元素，内部 XML 原样输出
+func (s *Serializer) serializeMetadata(builder *ssmlWriter, metadata *Metadata, depth int) error {
+	s.writeIndent(builder, depth)
+	builder.WriteString("<metadata")
+	s.writeAttrs(builder, depth, metadata.Attrs)
+	builder.WriteString(">")
+	builder.WriteString(metadata.InnerXML)
+	builder.WriteString("</metadata>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeDesc 序列化 desc 元素
+func (s *Serializer) serializeDesc(builder *ssmlWriter, desc *Desc, depth int) error {
+	s.writeIndent(builder, depth)
+	builder.WriteString("<desc")
+
+	if desc.Lang != "" {
+		builder.WriteString(fmt.Sprintf(` xml:lang="%s"`, s.escapeString(desc.Lang)))
+	}
+
+	s.writeAttrs(builder, depth, desc.Attrs)
+
+	if len(desc.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, desc.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</desc>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
 // serializeParagraph 序列化 p 元素
-func (s *Serializer) serializeParagraph(builder *strings.Builder, paragraph *Paragraph, depth int) error {
+func (s *Serializer) serializeParagraph(builder *ssmlWriter, paragraph *Paragraph, depth int) error {
 	s.writeIndent(builder, depth)
-	builder.WriteString("<p>")
+	builder.WriteString("<p")
+	s.writeAttrs(builder, depth, paragraph.Attrs)
+	builder.WriteString(">")
 	if s.Pretty {
 		builder.WriteString("\n")
 	}
@@ -271,7 +633,7 @@ func (s *Serializer) serializeParagraph(builder *strings.Builder, paragraph *Par
 }
 
 // serializePhoneme 序列化 phoneme 元素
-func (s *Serializer) serializePhoneme(builder *strings.Builder, phoneme *Phoneme, depth int) error {
+func (s *Serializer) serializePhoneme(builder *ssmlWriter, phoneme *Phoneme, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<phoneme")
 
@@ -282,6 +644,8 @@ func (s *Serializer) serializePhoneme(builder *strings.Builder, phoneme *Phoneme
 		builder.WriteString(fmt.Sprintf(` ph="%s"`, s.escapeString(phoneme.Ph)))
 	}
 
+	s.writeAttrs(builder, depth, phoneme.Attrs)
+
 	if len(phoneme.Content) == 0 {
 		builder.WriteString("/>")
 		if s.Pretty {
@@ -309,7 +673,7 @@ func (s *Serializer) serializePhoneme(builder *strings.Builder, phoneme *Phoneme
 }
 
 // serializeProsody 序列化 prosody 元素
-func (s *Serializer) serializeProsody(builder *strings.Builder, prosody *Prosody, depth int) error {
+func (s *Serializer) serializeProsody(builder *ssmlWriter, prosody *Prosody, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<prosody")
 
@@ -326,6 +690,8 @@ func (s *Serializer) serializeProsody(builder *strings.Builder, prosody *Prosody
 		builder.WriteString(fmt.Sprintf(` volume="%s"`, s.escapeString(prosody.Volume)))
 	}
 
+	s.writeAttrs(builder, depth, prosody.Attrs)
+
 	if len(prosody.Content) == 0 {
 		builder.WriteString("/>")
 		if s.Pretty {
@@ -353,9 +719,11 @@ func (s *Serializer) serializeProsody(builder *strings.Builder, prosody *Prosody
 }
 
 // serializeSentence 序列化 s 元素
-func (s *Serializer) serializeSentence(builder *strings.Builder, sentence *Sentence, depth int) error {
+func (s *Serializer) serializeSentence(builder *ssmlWriter, sentence *Sentence, depth int) error {
 	s.writeIndent(builder, depth)
-	builder.WriteString("<s>")
+	builder.WriteString("<s")
+	s.writeAttrs(builder, depth, sentence.Attrs)
+	builder.WriteString(">")
 	if s.Pretty {
 		builder.WriteString("\n")
 	}
@@ -373,8 +741,51 @@ func (s *Serializer) serializeSentence(builder *strings.Builder, sentence *Sente
 	return nil
 }
 
+// serializeSayAs 序列化 say-as 元素
+func (s *Serializer) serializeSayAs(builder *ssmlWriter, sayAs *SayAs, depth int) error {
+	s.writeIndent(builder, depth)
+	builder.WriteString("<say-as")
+
+	if sayAs.InterpretAs != "" {
+		builder.WriteString(fmt.Sprintf(` interpret-as="%s"`, s.escapeString(sayAs.InterpretAs)))
+	}
+	if sayAs.Format != "" {
+		builder.WriteString(fmt.Sprintf(` format="%s"`, s.escapeString(sayAs.Format)))
+	}
+	if sayAs.Detail != "" {
+		builder.WriteString(fmt.Sprintf(` detail="%s"`, s.escapeString(sayAs.Detail)))
+	}
+
+	s.writeAttrs(builder, depth, sayAs.Attrs)
+
+	if len(sayAs.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, sayAs.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</say-as>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
 // serializeSub 序列化 sub 元素
-func (s *Serializer) serializeSub(builder *strings.Builder, sub *Sub, depth int) error {
+func (s *Serializer) serializeSub(builder *ssmlWriter, sub *Sub, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<sub")
 
@@ -382,6 +793,8 @@ func (s *Serializer) serializeSub(builder *strings.Builder, sub *Sub, depth int)
 		builder.WriteString(fmt.Sprintf(` alias="%s"`, s.escapeString(sub.Alias)))
 	}
 
+	s.writeAttrs(builder, depth, sub.Attrs)
+
 	if len(sub.Content) == 0 {
 		builder.WriteString("/>")
 		if s.Pretty {
@@ -409,7 +822,7 @@ func (s *Serializer) serializeSub(builder *strings.Builder, sub *Sub, depth int)
 }
 
 // serializeVoice 序列化 voice 元素
-func (s *Serializer) serializeVoice(builder *strings.Builder, voice *Voice, depth int) error {
+func (s *Serializer) serializeVoice(builder *ssmlWriter, voice *Voice, depth int) error {
 	s.writeIndent(builder, depth)
 	builder.WriteString("<voice")
 
@@ -428,6 +841,11 @@ func (s *Serializer) serializeVoice(builder *strings.Builder, voice *Voice, dept
 	if voice.Languages != "" {
 		builder.WriteString(fmt.Sprintf(` xml:lang="%s"`, s.escapeString(voice.Languages)))
 	}
+	if voice.OnLangFailure != "" {
+		builder.WriteString(fmt.Sprintf(` onlangfailure="%s"`, s.escapeString(voice.OnLangFailure)))
+	}
+
+	s.writeAttrs(builder, depth, voice.Attrs)
 
 	if len(voice.Content) == 0 {
 		builder.WriteString("/>")
@@ -455,15 +873,62 @@ func (s *Serializer) serializeVoice(builder *strings.Builder, voice *Voice, dept
 	return nil
 }
 
-// serializeW 序列化 w 元素
-func (s *Serializer) serializeW(builder *strings.Builder, w *W, depth int) error {
+// serializeLang 序列化 lang 元素
+func (s *Serializer) serializeLang(builder *ssmlWriter, lang *Lang, depth int) error {
 	s.writeIndent(builder, depth)
-	builder.WriteString("<w")
+	builder.WriteString("<lang")
+
+	if lang.Lang != "" {
+		builder.WriteString(fmt.Sprintf(` xml:lang="%s"`, s.escapeString(lang.Lang)))
+	}
+	if lang.OnLangFailure != "" {
+		builder.WriteString(fmt.Sprintf(` onlangfailure="%s"`, s.escapeString(lang.OnLangFailure)))
+	}
+
+	s.writeAttrs(builder, depth, lang.Attrs)
+
+	if len(lang.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, lang.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</lang>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeW 序列化 w 和 token 元素
+func (s *Serializer) serializeW(builder *ssmlWriter, w *W, depth int) error {
+	tagName := "w"
+	if w.XMLName.Local == "token" {
+		tagName = "token"
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("<" + tagName)
 
 	if w.Role != "" {
 		builder.WriteString(fmt.Sprintf(` role="%s"`, s.escapeString(w.Role)))
 	}
 
+	s.writeAttrs(builder, depth, w.Attrs)
+
 	if len(w.Content) == 0 {
 		builder.WriteString("/>")
 		if s.Pretty {
@@ -482,7 +947,124 @@ func (s *Serializer) serializeW(builder *strings.Builder, w *W, depth int) error
 	}
 
 	s.writeIndent(builder, depth)
-	builder.WriteString("</w>")
+	builder.WriteString("</" + tagName + ">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeMSTTSExpressAs 序列化 mstts:express-as 元素
+func (s *Serializer) serializeMSTTSExpressAs(builder *ssmlWriter, expressAs *MSTTSExpressAs, depth int) error {
+	builder.declare(depth, expressAs.Attrs)
+	name, decl := builder.qualify(depth, vendorName(expressAs.XMLName, MSTTSNamespace, "express-as"), false)
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("<" + name + decl)
+
+	if expressAs.Style != "" {
+		builder.WriteString(fmt.Sprintf(` style="%s"`, s.escapeString(expressAs.Style)))
+	}
+	if expressAs.StyleDegree != "" {
+		builder.WriteString(fmt.Sprintf(` styledegree="%s"`, s.escapeString(expressAs.StyleDegree)))
+	}
+	if expressAs.Role != "" {
+		builder.WriteString(fmt.Sprintf(` role="%s"`, s.escapeString(expressAs.Role)))
+	}
+
+	s.writeAttrList(builder, depth, expressAs.Attrs)
+
+	if len(expressAs.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, expressAs.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</" + name + ">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeMSTTSSilence 序列化 mstts:silence 元素
+func (s *Serializer) serializeMSTTSSilence(builder *ssmlWriter, silence *MSTTSSilence, depth int) error {
+	builder.declare(depth, silence.Attrs)
+	name, decl := builder.qualify(depth, vendorName(silence.XMLName, MSTTSNamespace, "silence"), false)
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("<" + name + decl)
+
+	if silence.Type != "" {
+		builder.WriteString(fmt.Sprintf(` type="%s"`, s.escapeString(silence.Type)))
+	}
+	if silence.Value != "" {
+		builder.WriteString(fmt.Sprintf(` value="%s"`, s.escapeString(silence.Value)))
+	}
+
+	s.writeAttrList(builder, depth, silence.Attrs)
+
+	builder.WriteString("/>")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// serializeAmazonEffect 序列化 amazon:effect 元素
+func (s *Serializer) serializeAmazonEffect(builder *ssmlWriter, effect *AmazonEffect, depth int) error {
+	builder.declare(depth, effect.Attrs)
+	name, decl := builder.qualify(depth, vendorName(effect.XMLName, AmazonNamespace, "effect"), false)
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("<" + name + decl)
+
+	if effect.Name != "" {
+		builder.WriteString(fmt.Sprintf(` name="%s"`, s.escapeString(effect.Name)))
+	}
+	if effect.Phonation != "" {
+		builder.WriteString(fmt.Sprintf(` phonation="%s"`, s.escapeString(effect.Phonation)))
+	}
+	if effect.VocalTractLength != "" {
+		builder.WriteString(fmt.Sprintf(` vocal-tract-length="%s"`, s.escapeString(effect.VocalTractLength)))
+	}
+
+	s.writeAttrList(builder, depth, effect.Attrs)
+
+	if len(effect.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, effect.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</" + name + ">")
 	if s.Pretty {
 		builder.WriteString("\n")
 	}
@@ -490,8 +1072,108 @@ func (s *Serializer) serializeW(builder *strings.Builder, w *W, depth int) error
 	return nil
 }
 
+// serializeAmazonDomain 序列化 amazon:domain 元素
+func (s *Serializer) serializeAmazonDomain(builder *ssmlWriter, domain *AmazonDomain, depth int) error {
+	builder.declare(depth, domain.Attrs)
+	name, decl := builder.qualify(depth, vendorName(domain.XMLName, AmazonNamespace, "domain"), false)
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("<" + name + decl)
+
+	if domain.Name != "" {
+		builder.WriteString(fmt.Sprintf(` name="%s"`, s.escapeString(domain.Name)))
+	}
+
+	s.writeAttrList(builder, depth, domain.Attrs)
+
+	if len(domain.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, domain.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</" + name + ">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// vendorName 返回厂商元素的名称，未设置时使用默认命名空间和本地名
+func vendorName(name xml.Name, space, local string) xml.Name {
+	if name.Space == "" {
+		name.Space = space
+	}
+	if name.Local == "" {
+		name.Local = local
+	}
+	return name
+}
+
+// serializeUnknown 序列化未知元素，保留名称、命名空间和属性
+func (s *Serializer) serializeUnknown(builder *ssmlWriter, unknown *UnknownElement, depth int) error {
+	builder.declare(depth, unknown.Attrs)
+	name, decl := builder.qualify(depth, unknown.XMLName, false)
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("<" + name + decl)
+	s.writeAttrList(builder, depth, unknown.Attrs)
+
+	if len(unknown.Content) == 0 {
+		builder.WriteString("/>")
+		if s.Pretty {
+			builder.WriteString("\n")
+		}
+		return nil
+	}
+
+	builder.WriteString(">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	if err := s.serializeContent(builder, unknown.Content, depth+1); err != nil {
+		return err
+	}
+
+	s.writeIndent(builder, depth)
+	builder.WriteString("</" + name + ">")
+	if s.Pretty {
+		builder.WriteString("\n")
+	}
+
+	return nil
+}
+
+// writeAttrs 写入未识别的属性，并记录其中的命名空间声明
+func (s *Serializer) writeAttrs(builder *ssmlWriter, depth int, attrs []xml.Attr) {
+	builder.declare(depth, attrs)
+	s.writeAttrList(builder, depth, attrs)
+}
+
+// writeAttrList 写入属性列表，命名空间声明需已记录
+func (s *Serializer) writeAttrList(builder *ssmlWriter, depth int, attrs []xml.Attr) {
+	for _, attr := range attrs {
+		name, decl := builder.qualify(depth, attr.Name, true)
+		builder.WriteString(fmt.Sprintf(`%s %s="%s"`, decl, name, s.escapeString(attr.Value)))
+	}
+}
+
 // writeIndent 写入缩进
-func (s *Serializer) writeIndent(builder *strings.Builder, depth int) {
+func (s *Serializer) writeIndent(builder *ssmlWriter, depth int) {
 	if s.Pretty {
 		for i := 0; i < depth; i++ {
 			builder.WriteString(s.Indent)
@@ -0,0 +1,211 @@
+package ssml
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// EventType 流式解析事件类型
+type EventType int
+
+const (
+	EventStartElement EventType = iota
+	EventText
+	EventEndElement
+)
+
+// String 返回事件类型名称
+func (t EventType) String() string {
+	switch t {
+	case EventStartElement:
+		return "start"
+	case EventText:
+		return "text"
+	case EventEndElement:
+		return "end"
+	default:
+		return fmt.Sprintf("event(%d)", int(t))
+	}
+}
+
+// Event 流式解析事件
+type Event struct {
+	Type       EventType
+	Name       xml.Name         // 元素名，Text 事件为空
+	Node       interface{}      // 元素节点（不含子节点，如 *Prosody）或 Text
+	Text       string           // Text 事件的文本
+	Depth      int              // 嵌套深度，speak 为 0
+	Properties *AudioProperties // 当前生效的音频属性，已继承外层元素；元素事件为元素作用域内的属性
+	Span       Span             // 元素事件为开始标签的位置
+}
+
+// EventHandler 流式解析的事件回调，返回错误时停止解析
+type EventHandler interface {
+	HandleEvent(event Event) error
+}
+
+// EventHandlerFunc 函数形式的 EventHandler
+type EventHandlerFunc func(event Event) error
+
+// HandleEvent 调用函数本身
+func (f EventHandlerFunc) HandleEvent(event Event) error { return f(event) }
+
+// streamFrame 流式解析中尚未结束的元素
+type streamFrame struct {
+	name       xml.Name
+	node       interface{}
+	props      *AudioProperties
+	preserve   bool
+	span       Span
+	skipEvents bool
+}
+
+// Stream 边读取边解析 SSML，按文档顺序产生事件，不构建完整的文档树；
+// processor 提供默认音频属性，为 nil 时使用 NewAudioProcessor()。
+// 流式解析不支持恢复模式，也不报告 version、xml:lang 等文档级警告；
+// 输入大小和元素总数不受 MaxInputBytes、MaxElements 限制，其余按单个节点的限制照常检查
+func (p *Parser) Stream(reader io.Reader, processor *AudioProcessor, handler EventHandler) error {
+	return p.StreamContext(context.Background(), reader, processor, handler)
+}
+
+// StreamContext 与 Stream 相同，每读取一个 token 前检查 ctx，取消时返回 ctx.Err()
+func (p *Parser) StreamContext(ctx context.Context, reader io.Reader, processor *AudioProcessor, handler EventHandler) error {
+	if processor == nil {
+		processor = NewAudioProcessor()
+	}
+
+	// 流式解析的内存占用与文档大小无关，不限制整个文档的大小
+	decoder := newTokenDecoder(ctx, reader)
+	decoder.limits.perNode = true
+	var stack []streamFrame
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if cerr := contextError(err); cerr != nil {
+				return cerr
+			}
+			return fmt.Errorf("%s: XML parsing error: %w", decoder.position(), err)
+		}
+
+		switch se := token.(type) {
+		case xml.StartElement:
+			pos := decoder.tokenStart
+			frame, err := p.streamStart(decoder, se, pos, stack, processor)
+			if err != nil {
+				return err
+			}
+			if frame == nil {
+				continue
+			}
+
+			depth := len(stack)
+			if err := handler.HandleEvent(Event{Type: EventStartElement, Name: se.Name, Node: frame.node, Depth: depth, Properties: frame.props, Span: frame.span}); err != nil {
+				return err
+			}
+			if frame.skipEvents {
+				// 子节点已随元素一起解析，例如 metadata
+				if err := handler.HandleEvent(Event{Type: EventEndElement, Name: se.Name, Node: frame.node, Depth: depth, Properties: frame.props, Span: frame.span}); err != nil {
+					return err
+				}
+				continue
+			}
+			stack = append(stack, *frame)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			frame := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if err := handler.HandleEvent(Event{Type: EventEndElement, Name: frame.name, Node: frame.node, Depth: len(stack), Properties: frame.props, Span: frame.span}); err != nil {
+				return err
+			}
+
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+			if err := p.checkText(se, decoder.tokenStart); err != nil {
+				return err
+			}
+			top := stack[len(stack)-1]
+			decoder.preserveSpace = top.preserve
+			text, ok := p.textNode(decoder, se)
+			if !ok {
+				continue
+			}
+			if err := handler.HandleEvent(Event{Type: EventText, Node: text, Text: text.Content, Depth: len(stack), Properties: top.props, Span: text.Span}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// streamStart 解析开始标签，返回新元素的状态；根元素之外的内容被跳过时返回 nil
+func (p *Parser) streamStart(decoder *tokenDecoder, start xml.StartElement, pos Position, stack []streamFrame, processor *AudioProcessor) (*streamFrame, error) {
+	// shallow 只包含元素的结束标签，元素解析函数因此只读取属性，子节点由流式解析逐个产生
+	shallow := &tokenDecoder{
+		Decoder:       decoder.Decoder,
+		coreNamespace: decoder.coreNamespace,
+		namespaces:    decoder.namespaces,
+		pending:       []xml.Token{xml.EndElement{Name: start.Name}},
+		limits:        decoder.limits,
+		ctx:           decoder.ctx,
+	}
+
+	if len(stack) == 0 {
+		if start.Name.Local != "speak" {
+			return nil, decoder.Skip()
+		}
+		speak := &Speak{Span: Span{Start: pos}}
+		if err := p.parseSpeak(shallow, start, speak); err != nil {
+			return nil, err
+		}
+		decoder.coreNamespace = shallow.coreNamespace
+		decoder.namespaces = shallow.namespaces
+		return &streamFrame{
+			name:     start.Name,
+			node:     speak,
+			props:    processor.baseProperties(speak),
+			preserve: shallow.preserveSpace,
+			span:     Span{Start: pos, End: decoder.position()},
+		}, nil
+	}
+
+	parent := stack[len(stack)-1]
+	// 流式解析不递归，shallow 中的深度总是从 0 开始，这里按栈的深度检查
+	shallow.limits = &parseLimits{elements: decoder.limits.elements, depth: len(stack) - 1, perNode: true}
+	defer func() { decoder.limits.elements = shallow.limits.elements }()
+
+	shallow.preserveSpace = parent.preserve
+	// metadata 的内部 XML 随元素一起读取，没有单独的子节点事件
+	if _, custom := p.registry.Lookup(start.Name); !custom && start.Name.Local == "metadata" && shallow.isCore(start.Name) {
+		shallow.pending = nil
+	}
+	node, err := p.parseElement(shallow, start, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := &streamFrame{
+		name:     start.Name,
+		node:     node,
+		props:    processor.elementProperties(parent.props, node),
+		preserve: parent.preserve,
+		span:     Span{Start: pos, End: decoder.position()},
+	}
+	if space, ok := xmlSpace(start); ok {
+		frame.preserve = space == "preserve"
+	}
+	if _, ok := node.(*Metadata); ok {
+		frame.skipEvents = true
+	}
+
+	return frame, nil
+}
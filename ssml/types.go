@@ -2,20 +2,80 @@ package ssml
 
 import (
 	"encoding/xml"
+	"fmt"
 	"time"
 )
 
+// Position 源码中的位置，Line 和 Column 从 1 开始，Offset 为字节偏移
+type Position struct {
+	Line   int
+	Column int
+	Offset int64
+}
+
+// IsValid 判断位置是否有效（由 Builder 构建的节点没有位置信息）
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String 以 "行:列" 的形式输出位置
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span 节点在源码中的起止位置
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// GetSpan 返回节点的起止位置
+func (s Span) GetSpan() Span { return s }
+
+// Positioned 带有源码位置的节点
+type Positioned interface {
+	GetSpan() Span
+}
+
+// 命名空间
+const (
+	SSMLNamespace   = "http://www.w3.org/2001/10/synthesis"
+	MSTTSNamespace  = "https://www.w3.org/2001/mstts"
+	AmazonNamespace = "amazon" // Polly 的 amazon 前缀通常不声明命名空间
+)
+
+// Namespace 命名空间声明，Prefix 为空表示默认命名空间
+type Namespace struct {
+	Prefix string
+	URI    string
+}
+
+// Attr 返回命名空间声明对应的 xmlns 属性
+func (n Namespace) Attr() xml.Attr {
+	if n.Prefix == "" {
+		return xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: n.URI}
+	}
+	return xml.Attr{Name: xml.Name{Space: "xmlns", Local: n.Prefix}, Value: n.URI}
+}
+
 // SSML 根元素
 type Speak struct {
-	XMLName xml.Name `xml:"speak"`
-	Version string   `xml:"version,attr,omitempty"`
-	Lang    string   `xml:"xml:lang,attr,omitempty"`
-	Content []interface{}
+	XMLName       xml.Name    `xml:"speak"`
+	Version       string      `xml:"version,attr,omitempty"`
+	Lang          string      `xml:"xml:lang,attr,omitempty"`
+	OnLangFailure string      `xml:"onlangfailure,attr,omitempty"`
+	Namespaces    []Namespace // 解析时的 xmlns 声明，序列化时原样输出
+	Content       []interface{}
+	Attrs         []xml.Attr     `xml:",any,attr"`
+	Whitespace    WhitespaceMode `xml:"-"` // 解析时的空白处理模式；WhitespaceTrim 丢弃了元素之间的空白，生成纯文本时在相邻单词之间补上空格
+	Span
 }
 
 // 文本内容
 type Text struct {
 	Content string
+	Span
 }
 
 // 音频元素
@@ -23,13 +83,71 @@ type Audio struct {
 	XMLName xml.Name `xml:"audio"`
 	Src     string   `xml:"src,attr"`
 	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 停顿元素
 type Break struct {
-	XMLName  xml.Name `xml:"break"`
-	Time     string   `xml:"time,attr,omitempty"`
-	Strength string   `xml:"strength,attr,omitempty"`
+	XMLName  xml.Name   `xml:"break"`
+	Time     string     `xml:"time,attr,omitempty"`
+	Strength string     `xml:"strength,attr,omitempty"`
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 发音词典声明元素
+type Lexicon struct {
+	XMLName xml.Name   `xml:"lexicon"`
+	URI     string     `xml:"uri,attr"`
+	ID      string     `xml:"xml:id,attr"`
+	Type    string     `xml:"type,attr,omitempty"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 词典作用域元素
+type Lookup struct {
+	XMLName xml.Name `xml:"lookup"`
+	Ref     string   `xml:"ref,attr"`
+	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 书签元素
+type Mark struct {
+	XMLName xml.Name   `xml:"mark"`
+	Name    string     `xml:"name,attr"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 元信息元素
+type Meta struct {
+	XMLName   xml.Name   `xml:"meta"`
+	Name      string     `xml:"name,attr,omitempty"`
+	HTTPEquiv string     `xml:"http-equiv,attr,omitempty"`
+	Value     string     `xml:"content,attr"` // content 属性
+	Attrs     []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 元数据元素，保留原始的内部 XML
+type Metadata struct {
+	XMLName  xml.Name   `xml:"metadata"`
+	InnerXML string     `xml:",innerxml"`
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 音频描述元素
+type Desc struct {
+	XMLName xml.Name `xml:"desc"`
+	Lang    string   `xml:"xml:lang,attr,omitempty"`
+	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 强调元素
@@ -37,12 +155,16 @@ type Emphasis struct {
 	XMLName xml.Name `xml:"emphasis"`
 	Level   string   `xml:"level,attr,omitempty"`
 	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 段落元素
 type Paragraph struct {
 	XMLName xml.Name `xml:"p"`
 	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 发音指导元素
@@ -51,6 +173,8 @@ type Phoneme struct {
 	Alphabet string   `xml:"alphabet,attr,omitempty"`
 	Ph       string   `xml:"ph,attr"`
 	Content  []interface{}
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 韵律元素（音调、速度、音量等）
@@ -61,12 +185,16 @@ type Prosody struct {
 	Range   string   `xml:"range,attr,omitempty"`
 	Volume  string   `xml:"volume,attr,omitempty"`
 	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 句子元素
 type Sentence struct {
 	XMLName xml.Name `xml:"s"`
 	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 替换元素
@@ -74,31 +202,108 @@ type Sub struct {
 	XMLName xml.Name `xml:"sub"`
 	Alias   string   `xml:"alias,attr"`
 	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 读法元素（日期、数字、电话号码等）
+type SayAs struct {
+	XMLName     xml.Name `xml:"say-as"`
+	InterpretAs string   `xml:"interpret-as,attr"`
+	Format      string   `xml:"format,attr,omitempty"`
+	Detail      string   `xml:"detail,attr,omitempty"`
+	Content     []interface{}
+	Attrs       []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 声音元素
 type Voice struct {
-	XMLName   xml.Name `xml:"voice"`
-	Gender    string   `xml:"gender,attr,omitempty"`
-	Age       string   `xml:"age,attr,omitempty"`
-	Variant   string   `xml:"variant,attr,omitempty"`
-	Name      string   `xml:"name,attr,omitempty"`
-	Languages string   `xml:"xml:lang,attr,omitempty"`
-	Content   []interface{}
+	XMLName       xml.Name `xml:"voice"`
+	Gender        string   `xml:"gender,attr,omitempty"`
+	Age           string   `xml:"age,attr,omitempty"`
+	Variant       string   `xml:"variant,attr,omitempty"`
+	Name          string   `xml:"name,attr,omitempty"`
+	Languages     string   `xml:"xml:lang,attr,omitempty"`
+	OnLangFailure string   `xml:"onlangfailure,attr,omitempty"`
+	Content       []interface{}
+	Attrs         []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// 语言元素，切换语言但不切换声音
+type Lang struct {
+	XMLName       xml.Name `xml:"lang"`
+	Lang          string   `xml:"xml:lang,attr"`
+	OnLangFailure string   `xml:"onlangfailure,attr,omitempty"`
+	Content       []interface{}
+	Attrs         []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// Azure 说话风格元素 mstts:express-as
+type MSTTSExpressAs struct {
+	XMLName     xml.Name
+	Style       string     `xml:"style,attr,omitempty"`
+	StyleDegree string     `xml:"styledegree,attr,omitempty"`
+	Role        string     `xml:"role,attr,omitempty"`
+	Attrs       []xml.Attr `xml:",any,attr"`
+	Content     []interface{}
+	Span
+}
+
+// Azure 静音设置元素 mstts:silence
+type MSTTSSilence struct {
+	XMLName xml.Name
+	Type    string     `xml:"type,attr"`
+	Value   string     `xml:"value,attr"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
+}
+
+// Polly 声音效果元素 amazon:effect
+type AmazonEffect struct {
+	XMLName          xml.Name
+	Name             string     `xml:"name,attr,omitempty"`
+	Phonation        string     `xml:"phonation,attr,omitempty"`
+	VocalTractLength string     `xml:"vocal-tract-length,attr,omitempty"`
+	Attrs            []xml.Attr `xml:",any,attr"`
+	Content          []interface{}
+	Span
 }
 
-// 单词元素
+// Polly 说话领域元素 amazon:domain
+type AmazonDomain struct {
+	XMLName xml.Name
+	Name    string     `xml:"name,attr"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content []interface{}
+	Span
+}
+
+// 未知元素，保留名称、命名空间、属性和子节点
+type UnknownElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content []interface{}
+	Span
+}
+
+// 单词元素，SSML 1.1 的 token 元素也解析为 W（XMLName 为 token）
 type W struct {
 	XMLName xml.Name `xml:"w"`
 	Role    string   `xml:"role,attr,omitempty"`
 	Content []interface{}
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Span
 }
 
 // 解析结果结构
 type ParseResult struct {
 	Root     *Speak
-	Warnings []string
-	Errors   []string
+	Warnings []Diagnostic
+	Errors   []Diagnostic
+	Duration time.Duration // 预计的总时长，仅在开启 DurationCheck 时计算
 }
 
 // SSML 元素接口
@@ -114,6 +319,20 @@ func (t *Text) GetContent() []interface{}       { return nil }
 func (t *Text) SetContent(c []interface{})      { /* Text 没有子元素 */ }
 func (b *Break) GetContent() []interface{}      { return nil }
 func (b *Break) SetContent(c []interface{})     { /* Break 没有子元素 */ }
+func (l *Lexicon) GetContent() []interface{}    { return nil }
+func (l *Lexicon) SetContent(c []interface{})   { /* Lexicon 没有子元素 */ }
+func (l *Lookup) GetContent() []interface{}     { return l.Content }
+func (l *Lookup) SetContent(c []interface{})    { l.Content = c }
+func (m *Meta) GetContent() []interface{}       { return nil }
+func (m *Meta) SetContent(c []interface{})      { /* Meta 没有子元素 */ }
+func (m *Metadata) GetContent() []interface{}   { return nil }
+func (m *Metadata) SetContent(c []interface{})  { /* Metadata 保留原始 XML */ }
+func (d *Desc) GetContent() []interface{}       { return d.Content }
+func (d *Desc) SetContent(c []interface{})      { d.Content = c }
+func (l *Lang) GetContent() []interface{}       { return l.Content }
+func (l *Lang) SetContent(c []interface{})      { l.Content = c }
+func (m *Mark) GetContent() []interface{}       { return nil }
+func (m *Mark) SetContent(c []interface{})      { /* Mark 没有子元素 */ }
 func (a *Audio) GetContent() []interface{}      { return a.Content }
 func (a *Audio) SetContent(c []interface{})     { a.Content = c }
 func (e *Emphasis) GetContent() []interface{}   { return e.Content }
@@ -128,20 +347,67 @@ func (s *Sentence) GetContent() []interface{}   { return s.Content }
 func (s *Sentence) SetContent(c []interface{})  { s.Content = c }
 func (s *Sub) GetContent() []interface{}        { return s.Content }
 func (s *Sub) SetContent(c []interface{})       { s.Content = c }
+func (s *SayAs) GetContent() []interface{}      { return s.Content }
+func (s *SayAs) SetContent(c []interface{})     { s.Content = c }
 func (v *Voice) GetContent() []interface{}      { return v.Content }
 func (v *Voice) SetContent(c []interface{})     { v.Content = c }
 func (w *W) GetContent() []interface{}          { return w.Content }
 func (w *W) SetContent(c []interface{})         { w.Content = c }
 
+func (e *MSTTSExpressAs) GetContent() []interface{}  { return e.Content }
+func (e *MSTTSExpressAs) SetContent(c []interface{}) { e.Content = c }
+func (s *MSTTSSilence) GetContent() []interface{}    { return nil }
+func (s *MSTTSSilence) SetContent(c []interface{})   { /* MSTTSSilence 没有子元素 */ }
+func (e *AmazonEffect) GetContent() []interface{}    { return e.Content }
+func (e *AmazonEffect) SetContent(c []interface{})   { e.Content = c }
+func (d *AmazonDomain) GetContent() []interface{}    { return d.Content }
+func (d *AmazonDomain) SetContent(c []interface{})   { d.Content = c }
+func (u *UnknownElement) GetContent() []interface{}  { return u.Content }
+func (u *UnknownElement) SetContent(c []interface{}) { u.Content = c }
+
 // 验证器配置
 type ValidationConfig struct {
 	StrictMode           bool
 	AllowUnknownElements bool
 	MaxNestingDepth      int
 	MaxDuration          time.Duration
+	SuppressCodes        []DiagnosticCode  // 不报告的诊断代码
+	Whitespace           WhitespaceMode    // 文本空白处理模式，xml:space="preserve" 的元素总是保留空白
+	Recover              bool              // 恢复模式：修复格式错误的输入，每处修复记录为警告
+	Entities             map[string]string // 恢复模式下额外识别的实体，HTML 实体默认可用
+	Restructure          bool              // 非严格模式下自动调整不符合 SSML 1.1 内容模型的嵌套，如拆分包含 p 的 s
+
+	// 资源限制，0 表示不限制；超出时解析立即失败并返回 *LimitError
+	MaxInputBytes    int64         // 输入的最大字节数
+	MaxElements      int           // 元素的最大数量
+	MaxTextLength    int           // 单个文本节点的最大字节数
+	MaxAttributes    int           // 单个元素的最大属性数量
+	MaxBreakDuration time.Duration // break 的最大时长，DurationCheckWarn 时只报告警告，不中断解析
+
+	// 时长检查：解析后用 AudioProcessor 估算文档的时长，见 Parser.SetAudioProcessor
+	DurationCheck DurationCheck // 总时长超过 MaxDuration 或 break 超过 MaxBreakDuration 时的处理方式，默认不检查
 }
 
-// 默认验证配置
+// DurationCheck 时长检查的处理方式
+type DurationCheck int
+
+const (
+	DurationCheckOff    DurationCheck = iota // 不估算时长（默认）
+	DurationCheckWarn                        // 超出时报告警告
+	DurationCheckReject                      // 超出时报告错误，解析返回 ErrDurationTooLong
+)
+
+// WhitespaceMode 文本空白处理模式
+type WhitespaceMode int
+
+const (
+	WhitespaceTrim      WhitespaceMode = iota // 去掉文本首尾的空白，丢弃空白文本（默认）
+	WhitespaceNormalize                       // 将连续空白合并为一个空格，保留元素之间的空格
+	WhitespacePreserve                        // 原样保留所有空白
+)
+
+// 默认验证配置，不设置资源限制；处理不可信的输入时建议设置
+// MaxInputBytes: 16 << 20、MaxElements: 100000、MaxTextLength: 1 << 20、MaxAttributes: 64
 func DefaultValidationConfig() *ValidationConfig {
 	return &ValidationConfig{
 		StrictMode:           false,
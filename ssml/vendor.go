@@ -0,0 +1,331 @@
+package ssml
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// VendorProfile TTS 服务商支持的 SSML 子集和限制，用于在发送请求之前检查文档。
+// 字段为空或为 0 时不检查对应的项目
+type VendorProfile struct {
+	Name string
+
+	// Elements 支持的元素，厂商元素使用前缀，如 "mstts:express-as"、"amazon:effect"
+	Elements []string
+	// AttributeValues 属性允许的取值：元素 -> 属性 -> 取值，不区分大小写
+	AttributeValues map[string]map[string][]string
+	// SuppressCodes 不报告的诊断代码，与 ValidationConfig.SuppressCodes 相同
+	SuppressCodes []DiagnosticCode
+
+	MaxBreak       time.Duration // 单个 break 的最大时长
+	MaxVoices      int           // 文档中 voice 元素的最大数量
+	MaxCharacters  int           // 文本的最大字符数，不含标签
+	MaxInputLength int           // 序列化后整个文档的最大字符数，含标签
+	MaxInputBytes  int           // 序列化后整个文档的最大 UTF-8 字节数
+	MaxDuration    time.Duration // 用 AudioProcessor 估算的最大音频时长
+}
+
+// 内置的服务商配置，按各服务商公开的文档整理；服务商调整限制后可以复制一份再修改
+var (
+	// AzureProfile Azure 语音服务，元素按 Azure SSML 文档中的完整列表，包括 bookmark、MathML 和 mstts 扩展
+	AzureProfile = &VendorProfile{
+		Name: "Azure",
+		Elements: []string{
+			"speak", "voice", "p", "s", "break", "emphasis", "lang", "lexicon", "mark", "bookmark", "phoneme",
+			"prosody", "say-as", "sub", "audio", "{http://www.w3.org/1998/Math/MathML}math",
+			"mstts:express-as", "mstts:silence", "mstts:backgroundaudio", "mstts:audioduration",
+			"mstts:viseme", "mstts:ttsembedding",
+		},
+		AttributeValues: map[string]map[string][]string{
+			"phoneme": {"alphabet": {"ipa", "sapi", "ups", "x-sampa"}},
+		},
+		MaxBreak:    20 * time.Second,
+		MaxVoices:   50,
+		MaxDuration: 10 * time.Minute,
+	}
+
+	// PollyProfile Amazon Polly，不支持 voice 和 audio，声音在请求参数中指定
+	PollyProfile = &VendorProfile{
+		Name: "Polly",
+		Elements: []string{
+			"speak", "p", "s", "break", "emphasis", "lang", "mark", "phoneme", "prosody", "say-as",
+			"sub", "w", "amazon:effect", "amazon:domain",
+		},
+		AttributeValues: map[string]map[string][]string{
+			"phoneme":  {"alphabet": {"ipa", "x-sampa"}},
+			"emphasis": {"level": {"strong", "moderate", "reduced"}},
+			"say-as": {"interpret-as": {
+				"characters", "spell-out", "cardinal", "number", "ordinal", "digits", "fraction",
+				"unit", "date", "time", "address", "expletive", "telephone",
+			}},
+			"amazon:effect": {"name": {"drc", "whispered"}, "phonation": {"soft"}},
+			"amazon:domain": {"name": {"news", "conversational", "long-form", "music"}},
+		},
+		MaxBreak:       10 * time.Second,
+		MaxCharacters:  3000,
+		MaxInputLength: 6000,
+	}
+
+	// GoogleProfile Google Cloud Text-to-Speech，扩展元素解析为 UnknownElement
+	GoogleProfile = &VendorProfile{
+		Name: "Google",
+		Elements: []string{
+			"speak", "p", "s", "break", "emphasis", "lang", "mark", "phoneme", "prosody", "say-as",
+			"sub", "audio", "desc", "voice", "par", "seq", "media", "google:style",
+		},
+		AttributeValues: map[string]map[string][]string{
+			"phoneme": {"alphabet": {"ipa"}},
+		},
+		MaxBreak:      10 * time.Second,
+		MaxInputBytes: 5000,
+	}
+
+	// AlibabaProfile 阿里云智能语音交互，phoneme 使用拼音字母表 py
+	AlibabaProfile = &VendorProfile{
+		Name:     "Alibaba",
+		Elements: []string{"speak", "s", "break", "phoneme", "say-as", "sub", "w"},
+		AttributeValues: map[string]map[string][]string{
+			"phoneme": {"alphabet": {"py"}},
+			"say-as": {"interpret-as": {
+				"cardinal", "digits", "telephone", "name", "address", "id", "characters", "punctuation",
+				"date", "time", "currency", "measure",
+			}},
+		},
+		MaxBreak:      10 * time.Second,
+		MaxCharacters: 300,
+	}
+)
+
+// ValidateFor 检查文档是否在服务商支持的范围内，报告所有不支持的元素、属性值和超出的限制。
+// processor 用于估算 MaxDuration 的时长，应与实际合成使用同一个处理器，为 nil 时使用 NewAudioProcessor()。
+// 问题都是错误，profile.SuppressCodes 中的代码不报告，结果的 Root 为 s
+func (s *Speak) ValidateFor(profile *VendorProfile, processor *AudioProcessor) *ParseResult {
+	result := &ParseResult{
+		Root:     s,
+		Warnings: []Diagnostic{},
+		Errors:   []Diagnostic{},
+	}
+	if processor == nil {
+		processor = NewAudioProcessor()
+	}
+	check := &vendorCheck{profile: profile, processor: processor, result: result}
+
+	Walk(s, VisitorFuncs{OnEnter: func(cursor *Cursor) { check.node(cursor.Node()) }})
+	check.limits(s)
+	return result
+}
+
+// vendorCheck 一次 ValidateFor 检查的状态
+type vendorCheck struct {
+	profile    *VendorProfile
+	processor  *AudioProcessor
+	result     *ParseResult
+	voices     int
+	characters int
+}
+
+// report 记录错误，消息以服务商名称开头，被抑制的代码会被忽略
+func (c *vendorCheck) report(code DiagnosticCode, node interface{}, span Span, message, fix string) {
+	c.result.add(Diagnostic{
+		Code:     code,
+		Severity: SeverityError,
+		Message:  c.profile.Name + ": " + message,
+		Span:     span,
+		Node:     node,
+		Fix:      fix,
+	}, c.profile.SuppressCodes)
+}
+
+// node 检查单个节点的元素、属性值和 break、voice 的限制
+func (c *vendorCheck) node(node interface{}) {
+	if text := textOf(node); text != "" {
+		c.characters += utf8.RuneCountInString(strings.TrimSpace(text))
+		return
+	}
+
+	name := vendorElementName(node)
+	if name == "" {
+		return
+	}
+	span := spanOf(node)
+
+	if len(c.profile.Elements) > 0 && !containsFold(c.profile.Elements, name) {
+		c.report(CodeUnsupportedElement, node, span,
+			fmt.Sprintf("<%s> is not supported", name),
+			fmt.Sprintf("remove <%s> and keep its content", name))
+	}
+
+	attributes := c.profile.AttributeValues[name]
+	for _, attribute := range sortedAttributeNames(attributes) {
+		value := vendorAttribute(node, attribute)
+		if value == "" || containsFold(attributes[attribute], value) {
+			continue
+		}
+		c.report(CodeUnsupportedValue, node, span,
+			fmt.Sprintf("%s=%q on <%s> is not supported", attribute, value, name),
+			fmt.Sprintf("%s should be one of %s", attribute, quoteList(attributes[attribute])))
+	}
+
+	switch n := node.(type) {
+	case *Break:
+		if limit := c.profile.MaxBreak; limit > 0 {
+			if duration := breakDuration(n); duration > limit {
+				c.report(CodeVendorLimit, node, span,
+					fmt.Sprintf("break of %v exceeds the maximum of %v", duration, limit),
+					fmt.Sprintf("split the pause into breaks of at most %v", limit))
+			}
+		}
+	case *Voice:
+		c.voices++
+		if limit := c.profile.MaxVoices; limit > 0 && c.voices == limit+1 {
+			c.report(CodeVendorLimit, node, span,
+				fmt.Sprintf("more than %d <voice> elements in one document", limit),
+				"split the document into several requests")
+		}
+	}
+}
+
+// limits 检查整个文档的长度和时长
+func (c *vendorCheck) limits(speak *Speak) {
+	profile := c.profile
+	if limit := profile.MaxCharacters; limit > 0 && c.characters > limit {
+		c.report(CodeVendorLimit, speak, speak.Span,
+			fmt.Sprintf("text has %d characters, more than the maximum of %d", c.characters, limit),
+			"split the text into several requests")
+	}
+
+	if profile.MaxInputLength > 0 || profile.MaxInputBytes > 0 {
+		if output, err := NewSerializer(false).Serialize(speak); err == nil {
+			if limit := profile.MaxInputLength; limit > 0 && utf8.RuneCountInString(output) > limit {
+				c.report(CodeVendorLimit, speak, speak.Span,
+					fmt.Sprintf("document has %d characters including tags, more than the maximum of %d", utf8.RuneCountInString(output), limit),
+					"split the document into several requests")
+			}
+			if limit := profile.MaxInputBytes; limit > 0 && len(output) > limit {
+				c.report(CodeVendorLimit, speak, speak.Span,
+					fmt.Sprintf("document has %d bytes, more than the maximum of %d", len(output), limit),
+					"split the document into several requests")
+			}
+		}
+	}
+
+	if limit := profile.MaxDuration; limit > 0 {
+		estimate, err := c.processor.ProcessSSML(speak)
+		switch {
+		case err != nil:
+			// 无法估算时不能确认文档在限制之内
+			c.report(CodeVendorLimit, speak, speak.Span,
+				fmt.Sprintf("cannot estimate the duration to check the maximum of %v: %v", limit, err),
+				"fix the error, for example a lexicon that cannot be loaded")
+		case estimate.TotalDuration > limit:
+			c.report(CodeVendorLimit, speak, speak.Span,
+				fmt.Sprintf("estimated duration %v exceeds the maximum of %v", estimate.TotalDuration, limit),
+				"split the document into several requests")
+		}
+	}
+}
+
+// vendorElementName 返回服务商配置中使用的元素名，文本返回空
+func vendorElementName(node interface{}) string {
+	switch n := node.(type) {
+	case *MSTTSExpressAs:
+		return "mstts:express-as"
+	case *MSTTSSilence:
+		return "mstts:silence"
+	case *AmazonEffect:
+		return "amazon:effect"
+	case *AmazonDomain:
+		return "amazon:domain"
+	case *UnknownElement:
+		return qualifiedName(n.XMLName)
+	case CustomElement:
+		return qualifiedName(n.ElementName())
+	}
+	if name := modelName(node); name != "#text" {
+		return name
+	}
+	return ""
+}
+
+// vendorAttribute 返回节点的属性值，包括解析为字段的属性和保留在 Attrs 中的属性
+func vendorAttribute(node interface{}, name string) string {
+	var buf [8]attributeRule
+	for _, rule := range appendRequiredAttributes(appendAttributeRules(buf[:0], node), node) {
+		if rule.name == name {
+			return rule.value
+		}
+	}
+
+	switch n := node.(type) {
+	case *SayAs:
+		switch name {
+		case "format":
+			return n.Format
+		case "detail":
+			return n.Detail
+		}
+		return attrValue(n.Attrs, name)
+	case *Voice:
+		if name == "name" {
+			return n.Name
+		}
+		return attrValue(n.Attrs, name)
+	case *MSTTSExpressAs:
+		switch name {
+		case "style":
+			return n.Style
+		case "styledegree":
+			return n.StyleDegree
+		case "role":
+			return n.Role
+		}
+		return attrValue(n.Attrs, name)
+	case *MSTTSSilence:
+		switch name {
+		case "type":
+			return n.Type
+		case "value":
+			return n.Value
+		}
+		return attrValue(n.Attrs, name)
+	case *AmazonEffect:
+		switch name {
+		case "name":
+			return n.Name
+		case "phonation":
+			return n.Phonation
+		case "vocal-tract-length":
+			return n.VocalTractLength
+		}
+		return attrValue(n.Attrs, name)
+	case *AmazonDomain:
+		if name == "name" {
+			return n.Name
+		}
+		return attrValue(n.Attrs, name)
+	case *UnknownElement:
+		return attrValue(n.Attrs, name)
+	}
+	return ""
+}
+
+// containsFold 判断列表中是否有与 value 相同的取值，不区分大小写
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedAttributeNames 返回按字母排序的属性名，使诊断信息的顺序稳定
+func sortedAttributeNames(attributes map[string][]string) []string {
+	names := make(map[string]string, len(attributes))
+	for name := range attributes {
+		names[name] = ""
+	}
+	return sortedKeys(names)
+}
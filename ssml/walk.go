@@ -0,0 +1,272 @@
+package ssml
+
+import "sync"
+
+// Visitor 遍历文档树时的回调：Enter 在访问子节点之前调用，Leave 在访问子节点之后调用。
+// 回调中可以通过 Cursor 读取父节点链，跳过子节点，停止遍历，或替换、删除、插入节点
+type Visitor interface {
+	Enter(cursor *Cursor)
+	Leave(cursor *Cursor)
+}
+
+// VisitorFuncs 用函数实现 Visitor，为 nil 的函数不调用
+type VisitorFuncs struct {
+	OnEnter func(cursor *Cursor)
+	OnLeave func(cursor *Cursor)
+}
+
+// Enter 调用 OnEnter
+func (v VisitorFuncs) Enter(cursor *Cursor) {
+	if v.OnEnter != nil {
+		v.OnEnter(cursor)
+	}
+}
+
+// Leave 调用 OnLeave
+func (v VisitorFuncs) Leave(cursor *Cursor) {
+	if v.OnLeave != nil {
+		v.OnLeave(cursor)
+	}
+}
+
+// Walk 按文档顺序深度优先遍历 node 及其所有子节点，返回遍历后的根节点。
+// 根节点在回调中被替换时返回新节点，被删除时返回 nil。
+// 修改在遍历过程中立即生效，元素的内容在第一次修改时复制，不影响共用同一内容切片的其他节点。
+// 替换、插入的节点不会被遍历；在 Enter 中被替换或删除的节点不再访问子节点，也不调用 Leave
+func Walk(node interface{}, visitor Visitor) interface{} {
+	if node == nil {
+		return nil
+	}
+	w := walkerPool.Get().(*walker)
+	defer w.release()
+
+	w.visitor = visitor
+	w.rootItems[0] = node
+	w.root = contentEdit{items: w.rootItems[:]}
+	w.walkNode(&w.root, 0)
+	if len(w.root.items) == 0 {
+		return nil
+	}
+	return w.root.items[0]
+}
+
+// Cursor 当前访问的节点及其在父节点中的位置，只在回调中有效
+type Cursor struct {
+	walker  *walker
+	edit    *contentEdit
+	index   int
+	node    interface{}
+	skip    bool
+	removed bool // 已被替换或删除
+	deleted bool // 已被删除
+}
+
+// Node 返回当前节点
+func (c *Cursor) Node() interface{} {
+	return c.node
+}
+
+// Parent 返回当前节点的父元素，根节点返回 nil
+func (c *Cursor) Parent() SSMLElement {
+	parents := c.walker.parents
+	if len(parents) == 0 {
+		return nil
+	}
+	return parents[len(parents)-1]
+}
+
+// Ancestors 返回从根节点到父元素的祖先链，根节点返回空；切片在遍历中复用，需要保留时应复制
+func (c *Cursor) Ancestors() []SSMLElement {
+	parents := c.walker.parents
+	return parents[:len(parents):len(parents)]
+}
+
+// Depth 返回当前节点的深度，根节点为 0
+func (c *Cursor) Depth() int {
+	return len(c.walker.parents)
+}
+
+// Index 返回当前节点在父元素内容中的位置，根节点返回 -1
+func (c *Cursor) Index() int {
+	if c.Parent() == nil {
+		return -1
+	}
+	return c.index
+}
+
+// SkipChildren 在 Enter 中调用时不访问当前节点的子节点，仍然调用 Leave
+func (c *Cursor) SkipChildren() {
+	c.skip = true
+}
+
+// Stop 停止遍历，当前回调返回后不再调用任何回调，已做的修改保留
+func (c *Cursor) Stop() {
+	c.walker.stopped = true
+}
+
+// Replace 用 node 替换当前节点，node 不会被遍历；node 为 nil 时等同于 Delete
+func (c *Cursor) Replace(node interface{}) {
+	if c.removed {
+		return
+	}
+	if node == nil {
+		c.Delete()
+		return
+	}
+	c.edit.own()
+	c.edit.items[c.index] = node
+	c.node = node
+	c.removed = true
+}
+
+// Delete 从父元素中删除当前节点
+func (c *Cursor) Delete() {
+	if c.removed {
+		return
+	}
+	c.edit.own()
+	c.edit.items = append(c.edit.items[:c.index], c.edit.items[c.index+1:]...)
+	c.edit.step--
+	c.removed = true
+	c.deleted = true
+}
+
+// InsertBefore 在当前节点之前插入节点，插入的节点不会被遍历；根节点没有父元素，插入无效
+func (c *Cursor) InsertBefore(nodes ...interface{}) {
+	if c.Parent() == nil || len(nodes) == 0 {
+		return
+	}
+	c.edit.insert(c.index, nodes)
+	c.index += len(nodes)
+	c.edit.index += len(nodes)
+}
+
+// InsertAfter 在当前节点之后插入节点，插入的节点不会被遍历；根节点没有父元素，插入无效。
+// 当前节点已被删除时插入到原来的位置
+func (c *Cursor) InsertAfter(nodes ...interface{}) {
+	if c.Parent() == nil || len(nodes) == 0 {
+		return
+	}
+	at := c.index + 1
+	if c.deleted {
+		at = c.index
+	}
+	c.edit.insert(at, nodes)
+	c.edit.step += len(nodes)
+}
+
+// walker 一次 Walk 的状态，用完放回 walkerPool，各层的 Cursor、contentEdit 和父节点链在多次 Walk 之间复用
+type walker struct {
+	visitor   Visitor
+	cursors   []*Cursor // cursors[i] 为深度 i 的当前节点
+	parents   []SSMLElement
+	edits     []*contentEdit // edits[i] 为深度 i+1 的节点所在的内容
+	root      contentEdit
+	rootItems [1]interface{}
+	stopped   bool
+}
+
+// walkerPool 复用 walker，文档验证每次解析都会遍历文档树
+var walkerPool = sync.Pool{
+	New: func() interface{} { return &walker{} },
+}
+
+// release 清除 w 中对节点的引用并放回 walkerPool
+func (w *walker) release() {
+	for i := range w.parents {
+		w.parents[i] = nil
+	}
+	for _, cursor := range w.cursors {
+		*cursor = Cursor{}
+	}
+	for _, edit := range w.edits {
+		*edit = contentEdit{}
+	}
+	w.parents = w.parents[:0]
+	w.visitor = nil
+	w.root = contentEdit{}
+	w.rootItems[0] = nil
+	w.stopped = false
+	walkerPool.Put(w)
+}
+
+// contentEdit 正在遍历的内容切片；index 为当前位置，step 为访问完当前节点后前进的距离
+type contentEdit struct {
+	items []interface{}
+	index int
+	step  int
+	owned bool
+}
+
+// own 第一次修改前复制内容，解析得到的内容切片可能与其他节点共用底层数组
+func (e *contentEdit) own() {
+	if e.owned {
+		return
+	}
+	e.items = append(make([]interface{}, 0, len(e.items)+1), e.items...)
+	e.owned = true
+}
+
+// insert 在 at 处插入节点
+func (e *contentEdit) insert(at int, nodes []interface{}) {
+	e.own()
+	e.items = append(e.items, nodes...)
+	copy(e.items[at+len(nodes):], e.items[at:len(e.items)-len(nodes)])
+	copy(e.items[at:], nodes)
+}
+
+// walkNode 访问 edit 中 index 处的节点及其子节点
+func (w *walker) walkNode(edit *contentEdit, index int) {
+	// 每一层使用自己的 cursor，访问子节点不会改变父节点的 cursor
+	depth := len(w.parents)
+	if depth == len(w.cursors) {
+		w.cursors = append(w.cursors, &Cursor{})
+	}
+	cursor := w.cursors[depth]
+
+	node := edit.items[index]
+	edit.index = index
+	edit.step = 1
+	*cursor = Cursor{walker: w, edit: edit, index: index, node: node}
+
+	w.visitor.Enter(cursor)
+	if w.stopped || cursor.removed {
+		return
+	}
+
+	if element, ok := node.(SSMLElement); ok && !cursor.skip {
+		w.walkChildren(element)
+		if w.stopped {
+			return
+		}
+	}
+
+	w.visitor.Leave(cursor)
+}
+
+// walkChildren 遍历元素的内容，内容被修改时写回元素
+func (w *walker) walkChildren(element SSMLElement) {
+	content := element.GetContent()
+	if len(content) == 0 {
+		return
+	}
+
+	depth := len(w.parents)
+	if depth == len(w.edits) {
+		w.edits = append(w.edits, &contentEdit{})
+	}
+	edit := w.edits[depth]
+	*edit = contentEdit{items: content}
+
+	w.parents = append(w.parents, element)
+	for i := 0; i < len(edit.items) && !w.stopped; i = edit.index + edit.step {
+		w.walkNode(edit, i)
+	}
+	w.parents[depth] = nil
+	w.parents = w.parents[:depth]
+
+	if edit.owned {
+		element.SetContent(edit.items)
+	}
+	*edit = contentEdit{}
+}